@@ -0,0 +1,231 @@
+// Package app runs the bot. An App owns the Discord session, storage and commands, and starts and stops them in order.
+package app
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/mdb"
+	"github.com/bwmarrin/discordgo"
+)
+
+// App is the bot, from connecting to Discord through to handling every interaction.
+type App struct {
+	config     Config
+	session    *discordgo.Session
+	mdb        *mdb.MillionDollarBot
+	modules    []command.Module
+	guilds     command.Guilds
+	dispatcher *command.Dispatcher
+	publicKey  ed25519.PublicKey
+
+	// appId and server are set by Start.
+	appId  string
+	server *http.Server
+}
+
+// New creates an App from config, opening its storage. Nothing is sent to Discord until Start.
+func New(config Config) (*App, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	var publicKey ed25519.PublicKey
+	if config.HTTPAddr != "" {
+		// Already checked by Validate.
+		publicKey, _ = config.publicKey()
+	}
+
+	token, err := config.token()
+	if err != nil {
+		return nil, err
+	}
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bot parameters: %w", err)
+	}
+
+	log.Println("Starting mdb...")
+	store, err := NewStorage(config.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("can't open storage: %w", err)
+	}
+	bot := mdb.NewMillionDollarBot(store, config.MDBOptions()...)
+	modules := []command.Module{bot.Module()}
+
+	guilds, err := command.NewGuilds(config.Guilds, modules)
+	if err != nil {
+		bot.Close()
+		return nil, fmt.Errorf("invalid guilds: %w", err)
+	}
+
+	// Panics are always logged, and also sent to the admin channel if there is one.
+	var reporters []command.PanicReporter
+	if config.Admin.ChannelID != "" {
+		reporters = append(reporters, command.ReportToChannel(session, config.Admin.ChannelID))
+	}
+
+	dispatcher := command.NewDispatcher(modules, guilds,
+		command.Recover(reporters...),
+		command.Logging(),
+		command.Timing(time.Second),
+	)
+	dispatcher.Contact = config.Admin.Contact
+
+	return &App{
+		config:     config,
+		session:    session,
+		mdb:        bot,
+		modules:    modules,
+		guilds:     guilds,
+		dispatcher: dispatcher,
+		publicKey:  publicKey,
+	}, nil
+}
+
+// Start connects to Discord's gateway, or starts serving the interactions endpoint if Config.HTTPAddr is set, and
+// syncs the commands registered with Discord. Interactions are handled from then until Shutdown, which still has to
+// be called if Start fails.
+func (a *App) Start(ctx context.Context) error {
+	if a.config.HTTPAddr == "" {
+		a.session.AddHandler(a.dispatcher.HandleInteraction)
+		a.session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+			log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
+		})
+
+		if err := a.session.Open(); err != nil {
+			return fmt.Errorf("can't open the session: %w", err)
+		}
+		a.appId = a.session.State.User.ID
+	} else {
+		// Without a gateway connection, nothing tells us who we are.
+		app, err := a.session.Application("@me")
+		if err != nil {
+			return fmt.Errorf("can't look up the application: %w", err)
+		}
+		a.appId = app.ID
+
+		if err := a.serve(); err != nil {
+			return err
+		}
+	}
+
+	// This part registers the commands in Discord so they pop up when you type '/'. They stay registered while the bot
+	// is down, so this only changes anything when the commands have.
+	log.Println("Syncing commands...")
+	if err := a.syncCommands(ctx); err != nil {
+		return fmt.Errorf("can't sync commands: %w", err)
+	}
+
+	return nil
+}
+
+// serve starts serving Discord's HTTP interactions endpoint on Config.HTTPAddr.
+func (a *App) serve() error {
+	listener, err := net.Listen("tcp", a.config.HTTPAddr)
+	if err != nil {
+		return fmt.Errorf("can't serve interactions: %w", err)
+	}
+
+	// Discord wants a response within 3 seconds, so there's no point waiting long for the rest of a request.
+	a.server = &http.Server{
+		Handler:           command.NewEndpoint(a.dispatcher, a.publicKey, a.session),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       10 * time.Second,
+	}
+	go func() {
+		if err := a.server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Stopped serving interactions: %v", err)
+		}
+	}()
+	log.Printf("Serving interactions on %s", listener.Addr())
+
+	return nil
+}
+
+// syncCommands registers the commands of the modules enabled in each guild. Without any guilds, every module's commands
+// are registered globally instead.
+func (a *App) syncCommands(ctx context.Context) error {
+	globalCommands := command.ApplicationCommands(a.modules)
+	if a.guilds != nil {
+		// Anything left registered globally would show up alongside the per-guild commands.
+		globalCommands = nil
+	}
+	if err := a.syncIn(ctx, "", globalCommands); err != nil {
+		return err
+	}
+
+	for guildId := range a.guilds {
+		if err := a.syncIn(ctx, guildId, command.ApplicationCommands(a.guilds.Modules(guildId, a.modules))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *App) syncIn(ctx context.Context, guildId string, commands []*discordgo.ApplicationCommand) error {
+	where := "globally"
+	if guildId != "" {
+		where = "in guild " + guildId
+	}
+
+	changed, err := command.Sync(a.session, a.appId, guildId, commands, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("%s: %w", where, err)
+	} else if changed {
+		log.Printf("Commands updated %s.", where)
+	} else {
+		log.Printf("Commands already up to date %s.", where)
+	}
+
+	return nil
+}
+
+// Shutdown stops the App in order: it stops accepting interactions and waits for the ones in flight to finish, saves
+// stats, removes the commands from Discord if Config.Cleanup is set, then closes the session. Every step is attempted
+// even if an earlier one fails, but waiting gives up once ctx is done. Every error along the way is returned.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	// Anything arriving while in-flight interactions finish is told the bot is restarting, so the dispatcher drains
+	// before the server stops.
+	log.Println("Finishing interactions...")
+	if err := a.dispatcher.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("can't finish interactions: %w", err))
+	}
+	if a.server != nil {
+		if err := a.server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("can't stop serving interactions: %w", err))
+		}
+	}
+
+	log.Println("Saving stats...")
+	if err := a.mdb.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("can't save stats: %w", err))
+	}
+
+	if a.config.Cleanup && a.appId != "" {
+		log.Println("Removing commands...")
+		for _, guildId := range append([]string{""}, slices.Collect(maps.Keys(a.guilds))...) {
+			if err := command.Unregister(a.session, a.appId, guildId, discordgo.WithContext(ctx)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if err := a.session.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("can't close the session: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
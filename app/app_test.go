@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Scraniel/go-roboto-sensei/command/commandtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(t *testing.T) Config {
+	config := DefaultConfig()
+	config.Token = "token"
+	config.Storage.Path = filepath.Join(t.TempDir(), "stats.json")
+
+	return config
+}
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		config func(config *Config)
+		err    string
+	}{
+		"valid":       {func(config *Config) {}, ""},
+		"with guilds": {func(config *Config) { config.Guilds = map[string][]string{"123": {"mdb"}, "456": nil} }, ""},
+		"serving over HTTP": {func(config *Config) {
+			config.HTTPAddr = ":0"
+			config.PublicKey = strings.Repeat("ab", 32)
+		}, ""},
+		"sqlite":                  {func(config *Config) { config.Storage.Backend = "sqlite" }, ""},
+		"no token":                {func(config *Config) { config.Token = "" }, "no bot token"},
+		"no public key":           {func(config *Config) { config.HTTPAddr = ":0" }, "public key"},
+		"public key too short":    {func(config *Config) { config.HTTPAddr, config.PublicKey = ":0", hex.EncodeToString([]byte("short")) }, "public key"},
+		"unknown storage backend": {func(config *Config) { config.Storage.Backend = "floppy" }, "unknown storage backend"},
+		"unknown module":          {func(config *Config) { config.Guilds = map[string][]string{"123": {"nope"}} }, "invalid guilds"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := testConfig(t)
+			test.config(&config)
+
+			app, err := New(config)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	config := testConfig(t)
+	app, err := New(config)
+	require.NoError(t, err)
+
+	session := &commandtest.Session{}
+	question := func() {
+		app.dispatcher.Dispatch(session, commandtest.As("alice", commandtest.Command("mdb", commandtest.Subcommand("question"))).Interaction)
+	}
+	question()
+	app.dispatcher.Dispatch(session, commandtest.As("alice", commandtest.Command("mdb", commandtest.Subcommand("answer", commandtest.String("choice", "yes")))).Interaction)
+	require.Len(t, session.Messages(), 2)
+
+	// Stats are saved on the way out.
+	require.NoError(t, app.Shutdown(context.Background()))
+	assert.FileExists(t, config.Storage.Path)
+
+	// Anything arriving after shutdown is turned away.
+	question()
+	assert.Equal(t, commandtest.Message{Content: "I'm restarting! Try again in a minute.", Ephemeral: true}, session.Messages()[2])
+}
@@ -0,0 +1,355 @@
+package app
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/mdb"
+	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything the App needs to know to run. It's read from a YAML file by LoadConfig, with the same names
+// as the yaml tags below, and anything in the environment overrides it - see LoadEnv.
+type Config struct {
+	// Token is the bot's token from the Developer Portal. It's better kept out of the config file - set BOT_TOKEN, or
+	// point TokenFile at a file holding it instead.
+	Token string `yaml:"token"`
+	// TokenFile is a file holding the bot's token, like a mounted secret. Token wins if both are set.
+	TokenFile string `yaml:"token_file"`
+	// Guilds maps each guild to register commands in to the names of the modules enabled in it. A guild without any
+	// modules listed has them all enabled. Without any guilds, every command is registered globally.
+	Guilds map[string][]string `yaml:"guilds"`
+	// Cleanup removes the bot's commands from Discord on Shutdown.
+	Cleanup bool `yaml:"cleanup"`
+
+	// HTTPAddr is where Discord's HTTP interactions endpoint is served, like :8080. Without it, interactions are
+	// received over the gateway.
+	HTTPAddr string `yaml:"http_addr"`
+	// PublicKey is the application's public key from the Developer Portal, hex encoded. Only needed with HTTPAddr.
+	PublicKey string `yaml:"public_key"`
+
+	Admin   AdminConfig   `yaml:"admin"`
+	Storage StorageConfig `yaml:"storage"`
+	MDB     MDBConfig     `yaml:"mdb"`
+}
+
+// AdminConfig is who looks after the bot.
+type AdminConfig struct {
+	// Contact is who people are told to get in touch with when something goes wrong.
+	Contact string `yaml:"contact"`
+	// ChannelID is the channel panics are reported to, if set.
+	ChannelID string `yaml:"channel_id"`
+}
+
+// StorageConfig picks where stats are saved.
+type StorageConfig struct {
+	// Backend is json or sqlite. json is the default.
+	Backend string `yaml:"backend"`
+	// Path is where stats are saved, defaulting to ./stats.json or ./stats.db.
+	Path string `yaml:"path"`
+	// Backups is how many previous versions of the stats file are kept, for the json backend. Nil keeps the default
+	// number.
+	Backups *int `yaml:"backups"`
+	// LegacyGuildID is the guild that stats saved before they were kept per guild belong to.
+	LegacyGuildID string `yaml:"legacy_guild_id"`
+}
+
+// MDBConfig tunes the million dollar bot.
+type MDBConfig struct {
+	// MinCounterOffer and MaxCounterOffer bound the counter-offers people can make, in whole dollars. The min has to be
+	// at least 1, and less than the max.
+	MinCounterOffer float64 `yaml:"min_counter_offer"`
+	MaxCounterOffer float64 `yaml:"max_counter_offer"`
+
+	// Buttons gives questions buttons to answer them with.
+	Buttons bool `yaml:"buttons"`
+	// Leaderboard turns on `/mdb leaderboard`.
+	Leaderboard bool `yaml:"leaderboard"`
+	// Results turns on `/mdb results`.
+	Results bool `yaml:"results"`
+}
+
+// DefaultConfig is the config used for anything LoadConfig's file doesn't set.
+func DefaultConfig() Config {
+	return Config{
+		Admin: AdminConfig{Contact: command.DefaultContact},
+		MDB: MDBConfig{
+			MinCounterOffer: mdb.DefaultMinCounterOffer,
+			MaxCounterOffer: mdb.DefaultMaxCounterOffer,
+			Buttons:         true,
+			Leaderboard:     true,
+			Results:         true,
+		},
+	}
+}
+
+// LoadConfig reads the config file at path on top of DefaultConfig. Without a path, it's just DefaultConfig. Settings
+// it doesn't know about are an error, so typos don't go unnoticed.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("can't open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("can't read config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// LoadEnv overrides config with whichever of these environment variables are set, looked up with lookup (like
+// os.LookupEnv):
+//   - BOT_TOKEN and BOT_TOKEN_FILE
+//   - GUILDS, listed like -guilds, and CLEANUP
+//   - HTTP_ADDR and PUBLIC_KEY
+//   - ADMIN_CONTACT and ADMIN_CHANNEL_ID
+//   - STORAGE_BACKEND, SAVE_PATH, BACKUP_COUNT and LEGACY_GUILD_ID
+//   - MDB_MIN_COUNTER_OFFER, MDB_MAX_COUNTER_OFFER, MDB_BUTTONS, MDB_LEADERBOARD and MDB_RESULTS
+//
+// Every variable that can't be read is returned as an error.
+func (c *Config) LoadEnv(lookup func(key string) (string, bool)) error {
+	strs := map[string]*string{
+		"BOT_TOKEN":        &c.Token,
+		"BOT_TOKEN_FILE":   &c.TokenFile,
+		"HTTP_ADDR":        &c.HTTPAddr,
+		"PUBLIC_KEY":       &c.PublicKey,
+		"ADMIN_CONTACT":    &c.Admin.Contact,
+		"ADMIN_CHANNEL_ID": &c.Admin.ChannelID,
+		"STORAGE_BACKEND":  &c.Storage.Backend,
+		"SAVE_PATH":        &c.Storage.Path,
+		"LEGACY_GUILD_ID":  &c.Storage.LegacyGuildID,
+	}
+	for key, value := range strs {
+		if env, ok := lookup(key); ok {
+			*value = env
+		}
+	}
+
+	var errs []error
+	bools := map[string]*bool{
+		"CLEANUP":         &c.Cleanup,
+		"MDB_BUTTONS":     &c.MDB.Buttons,
+		"MDB_LEADERBOARD": &c.MDB.Leaderboard,
+		"MDB_RESULTS":     &c.MDB.Results,
+	}
+	for key, value := range bools {
+		if env, ok := lookup(key); ok {
+			b, err := strconv.ParseBool(env)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be true or false: %w", key, err))
+				continue
+			}
+			*value = b
+		}
+	}
+
+	floats := map[string]*float64{
+		"MDB_MIN_COUNTER_OFFER": &c.MDB.MinCounterOffer,
+		"MDB_MAX_COUNTER_OFFER": &c.MDB.MaxCounterOffer,
+	}
+	for key, value := range floats {
+		if env, ok := lookup(key); ok {
+			f, err := strconv.ParseFloat(env, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a number: %w", key, err))
+				continue
+			}
+			*value = f
+		}
+	}
+
+	if backupCount, ok := lookup("BACKUP_COUNT"); ok {
+		backups, err := strconv.Atoi(backupCount)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("BACKUP_COUNT must be a number: %w", err))
+		} else {
+			c.Storage.Backups = &backups
+		}
+	}
+
+	if list, ok := lookup("GUILDS"); ok {
+		guilds, err := command.ParseGuilds(list)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("GUILDS is invalid: %w", err))
+		} else {
+			c.Guilds = guilds
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks config makes sense, returning everything wrong with it. Guilds are checked by New, since that needs
+// the modules.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Token == "" && c.TokenFile == "" {
+		errs = append(errs, errors.New("token: no bot token is set - set BOT_TOKEN or token_file"))
+	}
+	if c.HTTPAddr != "" {
+		if _, err := c.publicKey(); err != nil {
+			errs = append(errs, fmt.Errorf("public_key: %w", err))
+		}
+	}
+
+	if err := c.ValidateModules(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateModules is the part of Validate that doesn't need Discord, checking only what the modules are run with -
+// for the console, which doesn't connect to Discord at all.
+func (c Config) ValidateModules() error {
+	var errs []error
+
+	if c.Admin.Contact == "" {
+		errs = append(errs, errors.New("admin.contact: has to say who to contact when something goes wrong"))
+	}
+
+	if err := c.Storage.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.MDB.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks config makes sense, returning everything wrong with it.
+func (s StorageConfig) Validate() error {
+	var errs []error
+
+	if s.Backend != "" && s.Backend != "json" && s.Backend != "sqlite" {
+		errs = append(errs, fmt.Errorf("storage.backend: unknown storage backend %q - use json or sqlite", s.Backend))
+	}
+	if s.Backups != nil && *s.Backups < 0 {
+		errs = append(errs, fmt.Errorf("storage.backups: can't keep %d backups", *s.Backups))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks config makes sense, returning everything wrong with it.
+func (m MDBConfig) Validate() error {
+	var errs []error
+
+	for _, bound := range []struct {
+		name    string
+		dollars float64
+	}{
+		{"mdb.min_counter_offer", m.MinCounterOffer},
+		{"mdb.max_counter_offer", m.MaxCounterOffer},
+	} {
+		// A counter-offer of 0 would be the same as answering no. A max of 0 would also mean no max at all to Discord.
+		if bound.dollars < 1 || bound.dollars != math.Trunc(bound.dollars) {
+			errs = append(errs, fmt.Errorf("%s: has to be a whole number of dollars, at least 1, not %v", bound.name, bound.dollars))
+		}
+	}
+	if m.MinCounterOffer >= m.MaxCounterOffer {
+		errs = append(errs, fmt.Errorf("mdb.min_counter_offer: %v has to be less than mdb.max_counter_offer (%v)", m.MinCounterOffer, m.MaxCounterOffer))
+	}
+
+	return errors.Join(errs...)
+}
+
+// token returns the bot's token, reading it from TokenFile if it isn't set directly.
+func (c Config) token() (string, error) {
+	if c.Token != "" || c.TokenFile == "" {
+		return c.Token, nil
+	}
+
+	contents, err := os.ReadFile(c.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("can't read token_file: %w", err)
+	}
+
+	// Files usually end in a newline, which isn't part of the token.
+	token := strings.TrimSpace(string(contents))
+	if token == "" {
+		return "", fmt.Errorf("token_file %s is empty", c.TokenFile)
+	}
+
+	return token, nil
+}
+
+// publicKey decodes PublicKey.
+func (c Config) publicKey() (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(c.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("the public key has to be set to the application's public key to serve interactions over HTTP")
+	}
+
+	return key, nil
+}
+
+// MDBOptions are the options for the million dollar bot picked by config.
+func (c Config) MDBOptions() []mdb.Option {
+	opts := []mdb.Option{
+		mdb.WithCounterOfferBounds(c.MDB.MinCounterOffer, c.MDB.MaxCounterOffer),
+		mdb.WithContact(c.Admin.Contact),
+	}
+	if !c.MDB.Buttons {
+		opts = append(opts, mdb.WithoutButtons())
+	}
+	if !c.MDB.Leaderboard {
+		opts = append(opts, mdb.WithoutLeaderboard())
+	}
+	if !c.MDB.Results {
+		opts = append(opts, mdb.WithoutResults())
+	}
+
+	return opts
+}
+
+// NewStorage creates the storage backend picked by config.
+func NewStorage(config StorageConfig) (storage.Storage, error) {
+	storageOpts := []storage.Option{storage.WithLegacyGuild(config.LegacyGuildID)}
+
+	switch config.Backend {
+	case "", "json":
+		path := config.Path
+		if path == "" {
+			log.Println("No save path set - using ./stats.json!")
+			path = "./stats.json"
+		}
+
+		if config.Backups != nil {
+			storageOpts = append(storageOpts, storage.WithBackups(*config.Backups))
+		}
+
+		return storage.NewLocalStorage(path, storageOpts...)
+	case "sqlite":
+		path := config.Path
+		if path == "" {
+			log.Println("No save path set - using ./stats.db!")
+			path = "./stats.db"
+		}
+
+		return storage.NewSQLiteStorage(path, storageOpts...)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q - use json or sqlite", config.Backend)
+	}
+}
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("without a file", func(t *testing.T) {
+		config, err := LoadConfig("")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), config)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		config, err := LoadConfig(writeFile(t, "config.yaml", ""))
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), config)
+	})
+
+	t.Run("on top of the defaults", func(t *testing.T) {
+		config, err := LoadConfig(writeFile(t, "config.yaml", `
+token_file: /run/secrets/token
+guilds:
+  123: [mdb]
+  "456": []
+admin:
+  contact: the mods
+storage:
+  backend: sqlite
+  backups: 0
+mdb:
+  max_counter_offer: 999
+  leaderboard: false
+`))
+		require.NoError(t, err)
+
+		expected := DefaultConfig()
+		expected.TokenFile = "/run/secrets/token"
+		expected.Guilds = map[string][]string{"123": {"mdb"}, "456": {}}
+		expected.Admin.Contact = "the mods"
+		expected.Storage.Backend = "sqlite"
+		expected.Storage.Backups = new(int)
+		expected.MDB.MaxCounterOffer = 999
+		expected.MDB.Leaderboard = false
+		assert.Equal(t, expected, config)
+	})
+
+	t.Run("example", func(t *testing.T) {
+		config, err := LoadConfig("../config.example.yaml")
+		require.NoError(t, err)
+
+		// It spells out the defaults.
+		expected := DefaultConfig()
+		expected.Storage.Backend = "json"
+		backups := 3
+		expected.Storage.Backups = &backups
+		assert.Equal(t, expected, config)
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		_, err := LoadConfig(writeFile(t, "config.yaml", "mdb:\n  max_counter_ofer: 10\n"))
+		assert.ErrorContains(t, err, "max_counter_ofer")
+	})
+
+	t.Run("rejects the wrong types", func(t *testing.T) {
+		_, err := LoadConfig(writeFile(t, "config.yaml", "mdb:\n  buttons: sometimes\n"))
+		assert.ErrorContains(t, err, "sometimes")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "nope.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestLoadEnv(t *testing.T) {
+	lookup := func(env map[string]string) func(string) (string, bool) {
+		return func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		}
+	}
+
+	t.Run("overrides what's set", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Token = "from the file"
+		config.Storage.Path = "./from-the-file.json"
+		require.NoError(t, config.LoadEnv(lookup(map[string]string{
+			"BOT_TOKEN":             "from the env",
+			"GUILDS":                "123=mdb,456",
+			"CLEANUP":               "true",
+			"HTTP_ADDR":             ":8080",
+			"PUBLIC_KEY":            "abcd",
+			"ADMIN_CONTACT":         "the mods",
+			"STORAGE_BACKEND":       "sqlite",
+			"BACKUP_COUNT":          "5",
+			"MDB_MIN_COUNTER_OFFER": "10",
+			"MDB_MAX_COUNTER_OFFER": "1000",
+			"MDB_BUTTONS":           "false",
+			"MDB_LEADERBOARD":       "0",
+		})))
+
+		expected := DefaultConfig()
+		expected.Token = "from the env"
+		expected.Guilds = map[string][]string{"123": {"mdb"}, "456": {}}
+		expected.Cleanup = true
+		expected.HTTPAddr = ":8080"
+		expected.PublicKey = "abcd"
+		expected.Admin.Contact = "the mods"
+		expected.Storage.Backend = "sqlite"
+		expected.Storage.Path = "./from-the-file.json"
+		backups := 5
+		expected.Storage.Backups = &backups
+		expected.MDB.MinCounterOffer = 10
+		expected.MDB.MaxCounterOffer = 1000
+		expected.MDB.Buttons = false
+		expected.MDB.Leaderboard = false
+		assert.Equal(t, expected, config)
+	})
+
+	t.Run("rejects what it can't read", func(t *testing.T) {
+		config := DefaultConfig()
+		err := config.LoadEnv(lookup(map[string]string{
+			"BACKUP_COUNT":          "lots",
+			"CLEANUP":               "sometimes",
+			"MDB_MAX_COUNTER_OFFER": "a million",
+			"GUILDS":                "1,1",
+		}))
+
+		assert.ErrorContains(t, err, "BACKUP_COUNT must be a number")
+		assert.ErrorContains(t, err, "CLEANUP must be true or false")
+		assert.ErrorContains(t, err, "MDB_MAX_COUNTER_OFFER must be a number")
+		assert.ErrorContains(t, err, "GUILDS is invalid")
+		assert.Equal(t, DefaultConfig(), config, "nothing unreadable is set")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		config func(config *Config)
+		errs   []string
+	}{
+		"valid":           {func(config *Config) {}, nil},
+		"token from file": {func(config *Config) { config.Token, config.TokenFile = "", "token" }, nil},
+		"no token":        {func(config *Config) { config.Token = "" }, []string{"token: no bot token is set"}},
+		"no contact":      {func(config *Config) { config.Admin.Contact = "" }, []string{"admin.contact"}},
+		"negative backups": {func(config *Config) {
+			backups := -1
+			config.Storage.Backups = &backups
+		}, []string{"storage.backups"}},
+		"fractional counter-offer": {func(config *Config) { config.MDB.MaxCounterOffer = 10.5 }, []string{"mdb.max_counter_offer: has to be a whole number"}},
+		"negative counter-offer":   {func(config *Config) { config.MDB.MinCounterOffer = -1 }, []string{"mdb.min_counter_offer: has to be a whole number of dollars, at least 1"}},
+		"min counter-offer of 0":   {func(config *Config) { config.MDB.MinCounterOffer = 0 }, []string{"mdb.min_counter_offer: has to be a whole number of dollars, at least 1, not 0"}},
+		"max counter-offer of 0": {func(config *Config) { config.MDB.MaxCounterOffer = 0 }, []string{
+			"mdb.max_counter_offer: has to be a whole number of dollars, at least 1, not 0",
+			"mdb.min_counter_offer: 1 has to be less than mdb.max_counter_offer (0)",
+		}},
+		"counter-offers backwards": {func(config *Config) {
+			config.MDB.MinCounterOffer, config.MDB.MaxCounterOffer = 10, 5
+		}, []string{"mdb.min_counter_offer: 10 has to be less than mdb.max_counter_offer (5)"}},
+		"counter-offers equal": {func(config *Config) {
+			config.MDB.MinCounterOffer, config.MDB.MaxCounterOffer = 10, 10
+		}, []string{"mdb.min_counter_offer: 10 has to be less than mdb.max_counter_offer (10)"}},
+		"everything at once": {func(config *Config) {
+			config.Token = ""
+			config.HTTPAddr = ":8080"
+			config.Storage.Backend = "floppy"
+		}, []string{"token:", "public_key:", "storage.backend: unknown storage backend \"floppy\""}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Token = "token"
+			test.config(&config)
+
+			err := config.Validate()
+			if test.errs == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Len(t, strings.Split(err.Error(), "\n"), len(test.errs))
+			for _, expected := range test.errs {
+				assert.ErrorContains(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestValidateModules(t *testing.T) {
+	config := DefaultConfig()
+	config.HTTPAddr = ":8080"
+	assert.NoError(t, config.ValidateModules(), "nothing needed for Discord is checked")
+
+	config.MDB.MinCounterOffer = 0
+	config.Storage.Backend = "floppy"
+	err := config.ValidateModules()
+	assert.ErrorContains(t, err, "mdb.min_counter_offer")
+	assert.ErrorContains(t, err, "storage.backend")
+}
+
+func TestToken(t *testing.T) {
+	config := DefaultConfig()
+	config.TokenFile = writeFile(t, "token", "secret\n")
+
+	token, err := config.token()
+	require.NoError(t, err)
+	assert.Equal(t, "secret", token)
+
+	config.Token = "wins"
+	token, err = config.token()
+	require.NoError(t, err)
+	assert.Equal(t, "wins", token)
+
+	config.Token, config.TokenFile = "", writeFile(t, "empty", "\n")
+	_, err = config.token()
+	assert.ErrorContains(t, err, "is empty")
+}
@@ -0,0 +1,94 @@
+package command
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxAutocompleteChoices is the most suggestions Discord shows for an option.
+	maxAutocompleteChoices = 25
+	// maxChoiceNameLength is the most characters Discord allows in the name of a suggestion.
+	maxChoiceNameLength = 100
+)
+
+// AutocompleteHandler suggests values for options defined with Autocomplete set, while they're being typed. A
+// MessageHandler that also implements AutocompleteHandler is asked for suggestions for its command's options.
+//
+// focused is the option being typed. Its value is whatever has been typed so far, which might not be valid yet.
+type AutocompleteHandler interface {
+	Autocomplete(req *Request, focused *discordgo.ApplicationCommandInteractionDataOption) ([]*discordgo.ApplicationCommandOptionChoice, error)
+}
+
+// handleAutocomplete asks the handler for the slash command in i for suggestions. Suggestions are asked for on every
+// keystroke, so unlike commands they skip the dispatcher's middleware, and failures just mean no suggestions.
+func (d *Dispatcher) handleAutocomplete(s Session, i *discordgo.Interaction) {
+	data := i.ApplicationCommandData()
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("autocomplete for %s panicked: %v\n%s", data.Name, recovered, debug.Stack())
+			choices = nil
+		}
+
+		err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{Choices: limitChoices(choices)},
+		})
+		if err != nil {
+			log.Printf("Cannot respond to autocomplete for %s: %v", data.Name, err)
+		}
+	}()
+
+	cmd, ok := d.commands[data.Name]
+	if i.Member == nil || !ok || !d.enabled(i.GuildID, data.Name) {
+		return
+	}
+
+	cmd, name, options, err := resolve(cmd, data.Options)
+	if err != nil {
+		log.Printf("no handler for autocomplete: %v", err)
+		return
+	}
+
+	h, ok := cmd.Handler.(AutocompleteHandler)
+	if !ok {
+		log.Printf("%s has autocomplete options but no AutocompleteHandler", name)
+		return
+	}
+
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, option := range options {
+		if option.Focused {
+			focused = option
+		}
+	}
+	if focused == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), responseWindow)
+	defer cancel()
+
+	choices, err = h.Autocomplete(newRequest(ctx, i, name, cmd.CommandInfo, options), focused)
+	if err != nil {
+		log.Printf("autocomplete for %s failed: %v", name, err)
+		choices = nil
+	}
+}
+
+// limitChoices trims choices down to what Discord accepts.
+func limitChoices(choices []*discordgo.ApplicationCommandOptionChoice) []*discordgo.ApplicationCommandOptionChoice {
+	choices = choices[:min(len(choices), maxAutocompleteChoices)]
+	for _, choice := range choices {
+		if name := []rune(choice.Name); len(name) > maxChoiceNameLength {
+			choice.Name = string(name[:maxChoiceNameLength-1]) + "…"
+		}
+	}
+
+	return choices
+}
@@ -0,0 +1,26 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitChoices(t *testing.T) {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for i := range 30 {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: fmt.Sprint(i), Value: i})
+	}
+	choices[0].Name = strings.Repeat("é", 150)
+
+	limited := limitChoices(choices)
+	assert.Len(t, limited, maxAutocompleteChoices)
+	assert.Len(t, []rune(limited[0].Name), maxChoiceNameLength)
+	assert.True(t, strings.HasSuffix(limited[0].Name, "…"))
+	assert.Equal(t, "1", limited[1].Name)
+
+	assert.Empty(t, limitChoices(nil))
+}
@@ -1,22 +1,97 @@
 package command
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/bwmarrin/discordgo"
+)
+
+// MessageHandler handles a slash command. Errors are for when something is broken - the person who used the command
+// is told something went wrong, and the error is logged. Anything they can fix themselves deserves a response instead.
 type MessageHandler interface {
-	Handle(caller *discordgo.Member, options map[string]interface{}) string
+	Handle(req *Request) (*Response, error)
 }
 
 type MessageCommand struct {
 	CommandInfo *discordgo.ApplicationCommand
 	Handler     MessageHandler
 	Key         string
+
+	// Deferred commands are acknowledged straight away, so their handler can take longer than the 3 seconds Discord
+	// gives everything else. Their response replaces the "thinking..." message once the handler returns.
+	Deferred bool
+	// Timeout is how long a deferred handler gets before it's given up on, unless it's committed to a change - see
+	// Request.Commit. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// Subcommands are handled instead of Handler when this command is a group - see NewGroup.
+	Subcommands []MessageCommand
 }
 
-func ToMap(options []*discordgo.ApplicationCommandInteractionDataOption) map[string]interface{} {
-	optionMap := make(map[string]interface{}, len(options))
-	for _, opt := range options {
-		optionMap[opt.Name] = opt.Value
+// NewGroup bundles commands up as the subcommands of one command called key, so they're used like `/key command`.
+// Groups can hold other groups, for commands like `/key group command`, but Discord doesn't allow nesting any deeper.
+func NewGroup(key, description string, commands ...MessageCommand) MessageCommand {
+	info := &discordgo.ApplicationCommand{
+		Type:        discordgo.ChatApplicationCommand,
+		Name:        key,
+		Description: description,
+	}
+
+	versions := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		option := &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        cmd.CommandInfo.Name,
+			Description: cmd.CommandInfo.Description,
+			Options:     cmd.CommandInfo.Options,
+		}
+		if len(cmd.Subcommands) > 0 {
+			option.Type = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+
+		info.Options = append(info.Options, option)
+		versions = append(versions, cmd.Key+"@"+cmd.CommandInfo.Version)
+	}
+	// The group changes whenever any of its commands do.
+	info.Version = strings.Join(versions, ",")
+
+	return MessageCommand{
+		CommandInfo: info,
+		Key:         key,
+		Subcommands: commands,
+	}
+}
+
+// resolve follows options down through cmd's subcommands to the command that handles them. It returns that command, its
+// full name (like /key group command) and the options it was used with.
+func resolve(cmd MessageCommand, options []*discordgo.ApplicationCommandInteractionDataOption) (MessageCommand, string, []*discordgo.ApplicationCommandInteractionDataOption, error) {
+	name := "/" + cmd.Key
+	for len(cmd.Subcommands) > 0 {
+		if len(options) != 1 || (options[0].Type != discordgo.ApplicationCommandOptionSubCommand && options[0].Type != discordgo.ApplicationCommandOptionSubCommandGroup) {
+			return cmd, name, nil, fmt.Errorf("%s was used without a subcommand", name)
+		}
+
+		subcommand := options[0]
+		found := false
+		for _, child := range cmd.Subcommands {
+			if child.Key == subcommand.Name {
+				cmd, found = child, true
+				break
+			}
+		}
+		if !found {
+			return cmd, name, nil, fmt.Errorf("%s has no subcommand %s", name, subcommand.Name)
+		}
+
+		name += " " + cmd.Key
+		options = subcommand.Options
+	}
+
+	if cmd.Handler == nil {
+		return cmd, name, nil, fmt.Errorf("%s has no handler", name)
 	}
 
-	return optionMap
+	return cmd, name, options, nil
 }
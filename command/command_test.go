@@ -0,0 +1,104 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubHandler struct{}
+
+func (stubHandler) Handle(req *Request) (*Response, error) {
+	return Message(req.Name), nil
+}
+
+func stubCommand(key string, options ...*discordgo.ApplicationCommandOption) MessageCommand {
+	return MessageCommand{
+		CommandInfo: &discordgo.ApplicationCommand{Name: key, Description: key + " things", Version: "1", Options: options},
+		Handler:     stubHandler{},
+		Key:         key,
+	}
+}
+
+func subcommand(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionSubCommand, Options: options}
+}
+
+func TestNewGroup(t *testing.T) {
+	countOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionInteger, Name: "count"}
+	group := NewGroup("top", "top things",
+		stubCommand("leaf", countOption),
+		NewGroup("admin", "admin things", stubCommand("reset")),
+	)
+
+	assert.Equal(t, "top", group.Key)
+	assert.Nil(t, group.Handler)
+	assert.Equal(t, "top", group.CommandInfo.Name)
+	assert.Equal(t, discordgo.ChatApplicationCommand, group.CommandInfo.Type)
+
+	require.Len(t, group.CommandInfo.Options, 2)
+	leaf, admin := group.CommandInfo.Options[0], group.CommandInfo.Options[1]
+	assert.Equal(t, &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "leaf",
+		Description: "leaf things",
+		Options:     []*discordgo.ApplicationCommandOption{countOption},
+	}, leaf)
+	assert.Equal(t, discordgo.ApplicationCommandOptionSubCommandGroup, admin.Type)
+	require.Len(t, admin.Options, 1)
+	assert.Equal(t, "reset", admin.Options[0].Name)
+	assert.Equal(t, discordgo.ApplicationCommandOptionSubCommand, admin.Options[0].Type)
+}
+
+func TestResolve(t *testing.T) {
+	group := NewGroup("top", "top things",
+		stubCommand("leaf"),
+		NewGroup("admin", "admin things", stubCommand("reset")),
+	)
+
+	t.Run("top-level command", func(t *testing.T) {
+		count := &discordgo.ApplicationCommandInteractionDataOption{Name: "count", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(1)}
+
+		cmd, name, options, err := resolve(stubCommand("plain"), []*discordgo.ApplicationCommandInteractionDataOption{count})
+		require.NoError(t, err)
+		assert.Equal(t, "plain", cmd.Key)
+		assert.Equal(t, "/plain", name)
+		assert.Equal(t, []*discordgo.ApplicationCommandInteractionDataOption{count}, options)
+	})
+
+	t.Run("subcommand", func(t *testing.T) {
+		count := &discordgo.ApplicationCommandInteractionDataOption{Name: "count", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(1)}
+
+		cmd, name, options, err := resolve(group, []*discordgo.ApplicationCommandInteractionDataOption{subcommand("leaf", count)})
+		require.NoError(t, err)
+		assert.Equal(t, "leaf", cmd.Key)
+		assert.Equal(t, "/top leaf", name)
+		assert.Equal(t, []*discordgo.ApplicationCommandInteractionDataOption{count}, options)
+	})
+
+	t.Run("subcommand group", func(t *testing.T) {
+		adminGroup := &discordgo.ApplicationCommandInteractionDataOption{
+			Name:    "admin",
+			Type:    discordgo.ApplicationCommandOptionSubCommandGroup,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{subcommand("reset")},
+		}
+
+		cmd, name, options, err := resolve(group, []*discordgo.ApplicationCommandInteractionDataOption{adminGroup})
+		require.NoError(t, err)
+		assert.Equal(t, "reset", cmd.Key)
+		assert.Equal(t, "/top admin reset", name)
+		assert.Empty(t, options)
+	})
+
+	t.Run("unknown subcommand", func(t *testing.T) {
+		_, _, _, err := resolve(group, []*discordgo.ApplicationCommandInteractionDataOption{subcommand("missing")})
+		assert.Error(t, err)
+	})
+
+	t.Run("missing subcommand", func(t *testing.T) {
+		_, _, _, err := resolve(group, nil)
+		assert.Error(t, err)
+	})
+}
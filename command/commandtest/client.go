@@ -0,0 +1,112 @@
+package commandtest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Client stands in for Discord when testing an interactions endpoint, sending it interactions signed with Key.
+type Client struct {
+	// URL is where the endpoint is served.
+	URL string
+	Key ed25519.PrivateKey
+	// HTTPClient sends the requests. http.DefaultClient is used if it's nil.
+	HTTPClient *http.Client
+}
+
+// NewClient creates a Client for the endpoint at url, signing with a newly generated key. The endpoint has to be
+// created with the client's PublicKey.
+func NewClient(url string) (*Client, error) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't generate key: %w", err)
+	}
+
+	return &Client{URL: url, Key: key}, nil
+}
+
+// PublicKey is the key the endpoint has to verify requests with.
+func (c *Client) PublicKey() ed25519.PublicKey {
+	return c.Key.Public().(ed25519.PublicKey)
+}
+
+// Send posts i to the endpoint, returning the response it sent back. Anything sent after it, like follow-ups, goes
+// to the endpoint's Session.
+func (c *Client) Send(i *discordgo.Interaction) (*discordgo.InteractionResponse, error) {
+	body, err := json.Marshal(i)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode interaction: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	Sign(req, body, c.Key)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("can't read response: %w", err)
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endpoint returned %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	return decodeInteractionResponse(respBody)
+}
+
+// Sign signs req, whose body is body, the way Discord signs the interactions it sends.
+func Sign(req *http.Request, body []byte, key ed25519.PrivateKey) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := ed25519.Sign(key, append([]byte(timestamp), body...))
+
+	req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(signature))
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+}
+
+// decodeInteractionResponse reads an interaction response, which discordgo can only encode because of its components.
+func decodeInteractionResponse(body []byte) (*discordgo.InteractionResponse, error) {
+	var raw struct {
+		Type discordgo.InteractionResponseType `json:"type"`
+		Data *struct {
+			discordgo.InteractionResponseData
+			Components []json.RawMessage `json:"components"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("can't decode response: %w", err)
+	}
+
+	response := &discordgo.InteractionResponse{Type: raw.Type}
+	if raw.Data != nil {
+		response.Data = &raw.Data.InteractionResponseData
+		for _, rawComponent := range raw.Data.Components {
+			component, err := discordgo.MessageComponentFromJSON(rawComponent)
+			if err != nil {
+				return nil, fmt.Errorf("can't decode component: %w", err)
+			}
+			response.Data.Components = append(response.Data.Components, component)
+		}
+	}
+
+	return response, nil
+}
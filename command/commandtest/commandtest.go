@@ -0,0 +1,158 @@
+// Package commandtest runs commands and components through a command.Dispatcher without Discord, for testing
+// handlers end to end. A Harness dispatches interactions built by the fixture functions, like Command and Click, to a
+// fake Session that records everything the dispatcher sends back.
+package commandtest
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// GuildID is the guild interactions are used in, unless they say otherwise.
+	GuildID = "guild"
+	// ChannelID is the channel interactions are used in, unless they say otherwise.
+	ChannelID = "channel"
+)
+
+// Harness dispatches interactions to the handlers of modules.
+type Harness struct {
+	Dispatcher *command.Dispatcher
+	Session    *Session
+
+	// lastId is the ID of the last interaction dispatched, so every interaction gets a new one.
+	lastId atomic.Int64
+}
+
+// New creates a Harness for the commands and components in modules, which are enabled in every guild. Handlers are
+// called through middleware, like they would be in the bot.
+func New(modules []command.Module, middleware ...command.Middleware) *Harness {
+	return &Harness{
+		Dispatcher: command.NewDispatcher(modules, nil, middleware...),
+		Session:    &Session{},
+	}
+}
+
+// Run dispatches i as if userId had used it, returning the messages sent in response. It's safe to call from several
+// goroutines at once.
+func (h *Harness) Run(userId string, i *discordgo.InteractionCreate) []Message {
+	i.ID = strconv.FormatInt(h.lastId.Add(1), 10)
+	As(userId, i)
+
+	h.Dispatcher.Dispatch(h.Session, i.Interaction)
+
+	return h.Session.MessagesFor(i.Interaction)
+}
+
+// As makes i look like userId used it. Interactions without a guild or channel are used in GuildID and ChannelID.
+func As(userId string, i *discordgo.InteractionCreate) *discordgo.InteractionCreate {
+	if i.GuildID == "" {
+		i.GuildID = GuildID
+	}
+	if i.ChannelID == "" {
+		i.ChannelID = ChannelID
+	}
+	i.Member = &discordgo.Member{
+		GuildID: i.GuildID,
+		User:    &discordgo.User{ID: userId, Username: userId},
+	}
+
+	return i
+}
+
+// Command builds a slash command interaction for the command called name, used with options.
+func Command(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return interaction(discordgo.InteractionApplicationCommand, discordgo.ApplicationCommandInteractionData{
+		ID:          name,
+		Name:        name,
+		CommandType: discordgo.ChatApplicationCommand,
+		Options:     options,
+	})
+}
+
+// Autocomplete builds an interaction asking for suggestions for the command called name, while it's being typed with
+// options. One of them should be Focused.
+func Autocomplete(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	i := Command(name, options...)
+	i.Type = discordgo.InteractionApplicationCommandAutocomplete
+
+	return i
+}
+
+// Click builds an interaction clicking the button with customId.
+func Click(customId string) *discordgo.InteractionCreate {
+	return interaction(discordgo.InteractionMessageComponent, discordgo.MessageComponentInteractionData{
+		CustomID:      customId,
+		ComponentType: discordgo.ButtonComponent,
+	})
+}
+
+// Submit builds an interaction submitting the modal with customId, with values typed into its text inputs, keyed by
+// their custom IDs.
+func Submit(customId string, values map[string]string) *discordgo.InteractionCreate {
+	data := discordgo.ModalSubmitInteractionData{CustomID: customId}
+	for inputId, value := range values {
+		data.Components = append(data.Components, &discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{&discordgo.TextInput{CustomID: inputId, Value: value}},
+		})
+	}
+
+	return interaction(discordgo.InteractionModalSubmit, data)
+}
+
+func interaction(interactionType discordgo.InteractionType, data discordgo.InteractionData) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:   interactionType,
+		Data:   data,
+		Locale: discordgo.EnglishUS,
+	}}
+}
+
+// Subcommand builds the option for using the subcommand called name, with options.
+func Subcommand(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionSubCommand, Options: options}
+}
+
+// SubcommandGroup builds the option for using a subcommand in the group called name.
+func SubcommandGroup(name string, subcommand *discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:    name,
+		Type:    discordgo.ApplicationCommandOptionSubCommandGroup,
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{subcommand},
+	}
+}
+
+func String(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionString, value)
+}
+
+// Int builds an integer option. Like Discord, its value is sent as a float64.
+func Int(name string, value int64) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionInteger, float64(value))
+}
+
+func Number(name string, value float64) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionNumber, value)
+}
+
+func Bool(name string, value bool) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionBoolean, value)
+}
+
+// User builds a user option, which is sent as the user's ID.
+func User(name, userId string) *discordgo.ApplicationCommandInteractionDataOption {
+	return option(name, discordgo.ApplicationCommandOptionUser, userId)
+}
+
+// Focused marks option as the one being typed, for Autocomplete.
+func Focused(option *discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	option.Focused = true
+	return option
+}
+
+func option(name string, optionType discordgo.ApplicationCommandOptionType, value any) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: optionType, Value: value}
+}
@@ -0,0 +1,262 @@
+package commandtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/command/commandtest"
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// greetHandler greets whoever used it, or whoever they asked it to.
+type greetHandler struct {
+	response *command.Response
+}
+
+func (h greetHandler) Handle(req *command.Request) (*command.Response, error) {
+	if h.response != nil {
+		return h.response, nil
+	}
+
+	var options struct {
+		Name  string `option:"name"`
+		Times int    `option:"times"`
+	}
+	options.Times = 1
+	if err := req.Decode(&options); err != nil {
+		return nil, err
+	}
+	if options.Name == "" {
+		options.Name = req.Member.User.ID
+	}
+
+	return command.Message(fmt.Sprintf("hi %s x%d in %s", options.Name, options.Times, req.ChannelID)), nil
+}
+
+func (greetHandler) Autocomplete(req *command.Request, focused *discordgo.ApplicationCommandInteractionDataOption) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	return []*discordgo.ApplicationCommandOptionChoice{{Name: focused.StringValue() + "!", Value: focused.StringValue()}}, nil
+}
+
+func (greetHandler) HandleComponent(req *command.Request, args []string) (*command.Response, error) {
+	if req.Interaction.Type == discordgo.InteractionModalSubmit {
+		return command.Message(fmt.Sprintf("%s said %v", req.Member.User.ID, command.ModalValues(req.Interaction.ModalSubmitData()))), nil
+	}
+
+	return command.Message(fmt.Sprintf("%s clicked %v", req.Member.User.ID, args)), nil
+}
+
+func greet(key string, handler greetHandler, deferred bool) command.MessageCommand {
+	return command.MessageCommand{
+		CommandInfo: &discordgo.ApplicationCommand{
+			Name:        key,
+			Description: "greets people",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "name", Autocomplete: true},
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "times"},
+			},
+		},
+		Handler:  handler,
+		Key:      key,
+		Deferred: deferred,
+	}
+}
+
+func newHarness(middleware ...command.Middleware) *commandtest.Harness {
+	return commandtest.New([]command.Module{{
+		Name: "greetings",
+		Commands: []command.MessageCommand{
+			greet("greet", greetHandler{}, false),
+			command.NewGroup("later", "greets people later",
+				greet("greet", greetHandler{}, true),
+				greet("whisper", greetHandler{response: &command.Response{
+					Content:   "psst",
+					Ephemeral: true,
+					FollowUps: []*command.Response{command.Ephemeral("psst again")},
+				}}, true),
+			),
+		},
+		Components: []command.MessageComponent{{Handler: greetHandler{}, Key: "greeting"}},
+	}}, middleware...)
+}
+
+func methods(calls []commandtest.Call) []string {
+	var names []string
+	for _, call := range calls {
+		names = append(names, call.Method)
+	}
+
+	return names
+}
+
+func TestCommand(t *testing.T) {
+	h := newHarness()
+
+	messages := h.Run("alice", commandtest.Command("greet"))
+	assert.Equal(t, []commandtest.Message{{Content: "hi alice x1 in channel"}}, messages)
+
+	messages = h.Run("alice", commandtest.Command("greet", commandtest.String("name", "bob"), commandtest.Int("times", 3)))
+	assert.Equal(t, []commandtest.Message{{Content: "hi bob x3 in channel"}}, messages)
+
+	i := commandtest.Command("greet")
+	i.ChannelID = "elsewhere"
+	messages = h.Run("alice", i)
+	assert.Equal(t, []commandtest.Message{{Content: "hi alice x1 in elsewhere"}}, messages)
+
+	calls := h.Session.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, commandtest.GuildID, calls[0].Interaction.GuildID)
+	assert.NotEqual(t, calls[0].Interaction.ID, calls[1].Interaction.ID)
+}
+
+func TestCommandRejected(t *testing.T) {
+	h := newHarness()
+
+	messages := h.Run("alice", commandtest.Command("greet", commandtest.String("times", "lots")))
+	assert.Equal(t, []commandtest.Message{{Content: "Hmm, `times` has to be a whole number!", Ephemeral: true}}, messages)
+
+	messages = h.Run("alice", commandtest.Command("gone"))
+	assert.Equal(t, []commandtest.Message{{Content: "That command doesn't do anything anymore!", Ephemeral: true}}, messages)
+
+	messages = h.Run("alice", commandtest.Command("later", commandtest.Subcommand("gone")))
+	assert.Equal(t, []commandtest.Message{{Content: "That command doesn't do anything anymore!", Ephemeral: true}}, messages)
+}
+
+func TestDeferredCommand(t *testing.T) {
+	h := newHarness()
+
+	messages := h.Run("alice", commandtest.Command("later", commandtest.Subcommand("greet", commandtest.String("name", "bob"))))
+	assert.Equal(t, []commandtest.Message{{Content: "hi bob x1 in channel"}}, messages)
+	assert.Equal(t, []string{"InteractionRespond", "InteractionResponseEdit"}, methods(h.Session.Calls()))
+	assert.Equal(t, discordgo.InteractionResponseDeferredChannelMessageWithSource, h.Session.Calls()[0].Response.Type)
+}
+
+func TestDeferredEphemeralCommand(t *testing.T) {
+	h := newHarness()
+
+	messages := h.Run("alice", commandtest.Command("later", commandtest.Subcommand("whisper")))
+	assert.Equal(t, []commandtest.Message{
+		{Content: "psst", Ephemeral: true},
+		{Content: "psst again", Ephemeral: true},
+	}, messages)
+	assert.Equal(t, []string{
+		"InteractionRespond",
+		"InteractionResponseDelete",
+		"FollowupMessageCreate",
+		"FollowupMessageCreate",
+	}, methods(h.Session.Calls()))
+}
+
+func TestComponents(t *testing.T) {
+	h := newHarness()
+
+	messages := h.Run("alice", commandtest.Click(command.CustomId("greeting", "wave")))
+	assert.Equal(t, []commandtest.Message{{Content: "alice clicked [wave]"}}, messages)
+
+	messages = h.Run("bob", commandtest.Submit(command.CustomId("greeting"), map[string]string{"reply": "hello"}))
+	assert.Equal(t, []commandtest.Message{{Content: "bob said map[reply:hello]"}}, messages)
+
+	messages = h.Run("bob", commandtest.Click("gone"))
+	assert.Equal(t, []commandtest.Message{{Content: "That doesn't do anything anymore!", Ephemeral: true}}, messages)
+}
+
+func TestAutocomplete(t *testing.T) {
+	h := newHarness()
+
+	messages := h.Run("alice", commandtest.Autocomplete("greet", commandtest.Focused(commandtest.String("name", "bo"))))
+	assert.Empty(t, messages)
+
+	calls := h.Session.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, discordgo.InteractionApplicationCommandAutocompleteResult, calls[0].Response.Type)
+	assert.Equal(t, []*discordgo.ApplicationCommandOptionChoice{{Name: "bo!", Value: "bo"}}, calls[0].Response.Data.Choices)
+}
+
+func TestMiddleware(t *testing.T) {
+	var used []string
+	h := newHarness(func(next command.HandlerFunc) command.HandlerFunc {
+		return func(req *command.Request) (*command.Response, error) {
+			used = append(used, req.Name)
+			return next(req)
+		}
+	})
+
+	h.Run("alice", commandtest.Command("greet"))
+	h.Run("alice", commandtest.Command("later", commandtest.Subcommand("greet")))
+	h.Run("alice", commandtest.Click("greeting"))
+	assert.Equal(t, []string{"/greet", "/later greet", "greeting"}, used)
+}
+
+func TestSessionErr(t *testing.T) {
+	h := newHarness()
+	h.Session.Err = errors.New("discord is down")
+
+	h.Run("alice", commandtest.Command("later", commandtest.Subcommand("greet")))
+	assert.Equal(t, []string{"InteractionRespond"}, methods(h.Session.Calls()), "nothing more should be sent once deferring fails")
+}
+
+// waitHandler responds once it's released.
+type waitHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h waitHandler) Handle(req *command.Request) (*command.Response, error) {
+	close(h.started)
+	<-h.release
+	return command.Message("finally"), nil
+}
+
+func TestDrain(t *testing.T) {
+	handler := waitHandler{started: make(chan struct{}), release: make(chan struct{})}
+	h := commandtest.New([]command.Module{{
+		Name: "waiting",
+		Commands: []command.MessageCommand{
+			{CommandInfo: &discordgo.ApplicationCommand{Name: "wait"}, Handler: handler, Key: "wait", Deferred: true},
+			greet("greet", greetHandler{}, false),
+		},
+	}})
+
+	waited := make(chan []commandtest.Message)
+	go func() { waited <- h.Run("alice", commandtest.Command("wait")) }()
+	<-handler.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, h.Dispatcher.Drain(ctx), context.DeadlineExceeded, "the handler hasn't finished yet")
+
+	// Nothing new is handled once draining has started.
+	messages := h.Run("bob", commandtest.Command("greet"))
+	assert.Equal(t, []commandtest.Message{{Content: "I'm restarting! Try again in a minute.", Ephemeral: true}}, messages)
+
+	close(handler.release)
+	assert.NoError(t, h.Dispatcher.Drain(context.Background()))
+	assert.Equal(t, []commandtest.Message{{Content: "finally"}}, <-waited)
+}
+
+func TestDrainTimedOut(t *testing.T) {
+	handler := waitHandler{started: make(chan struct{}), release: make(chan struct{})}
+	h := commandtest.New([]command.Module{{
+		Name: "waiting",
+		Commands: []command.MessageCommand{
+			{CommandInfo: &discordgo.ApplicationCommand{Name: "wait"}, Handler: handler, Key: "wait", Deferred: true, Timeout: time.Millisecond},
+		},
+	}})
+
+	messages := h.Run("alice", commandtest.Command("wait"))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "too long")
+
+	// The handler's been given up on, but it's still running - and could still be using storage.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, h.Dispatcher.Drain(ctx), context.DeadlineExceeded, "the handler hasn't finished yet")
+
+	close(handler.release)
+	assert.NoError(t, h.Dispatcher.Drain(context.Background()))
+}
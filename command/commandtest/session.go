@@ -0,0 +1,132 @@
+package commandtest
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Call is a call the dispatcher made to a Session. Only the field for its Method is set.
+type Call struct {
+	Method      string
+	Interaction *discordgo.Interaction
+
+	// Response is what was passed to InteractionRespond.
+	Response *discordgo.InteractionResponse
+	// Edit is what was passed to InteractionResponseEdit.
+	Edit *discordgo.WebhookEdit
+	// FollowUp is what was passed to FollowupMessageCreate.
+	FollowUp *discordgo.WebhookParams
+}
+
+// Message is a message the dispatcher sent in response to an interaction, whichever way it was sent.
+type Message struct {
+	Content    string
+	Embeds     []*discordgo.MessageEmbed
+	Components []discordgo.MessageComponent
+	Ephemeral  bool
+}
+
+// Session is a fake command.Session, recording every call made to it instead of calling Discord.
+type Session struct {
+	// Err, if set, is returned from every call, as if Discord had refused it. The call is still recorded.
+	Err error
+
+	lock  sync.Mutex
+	calls []Call
+}
+
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.record(Call{Method: "InteractionRespond", Interaction: interaction, Response: resp})
+	return s.Err
+}
+
+func (s *Session) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.record(Call{Method: "InteractionResponseEdit", Interaction: interaction, Edit: newresp})
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	return &discordgo.Message{ChannelID: interaction.ChannelID}, nil
+}
+
+func (s *Session) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	s.record(Call{Method: "InteractionResponseDelete", Interaction: interaction})
+	return s.Err
+}
+
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.record(Call{Method: "FollowupMessageCreate", Interaction: interaction, FollowUp: data})
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	return &discordgo.Message{ChannelID: interaction.ChannelID, Content: data.Content}, nil
+}
+
+func (s *Session) record(call Call) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.calls = append(s.calls, call)
+}
+
+// Calls returns every call made to the session, in order.
+func (s *Session) Calls() []Call {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// Messages returns every message sent through the session, in order. Deferring a response, opening a modal and
+// suggesting options don't send messages - look at Calls for those.
+func (s *Session) Messages() []Message {
+	return s.messages(func(Call) bool { return true })
+}
+
+// MessagesFor returns the messages sent in response to interaction, in order.
+func (s *Session) MessagesFor(interaction *discordgo.Interaction) []Message {
+	return s.messages(func(call Call) bool { return call.Interaction == interaction })
+}
+
+func (s *Session) messages(include func(call Call) bool) []Message {
+	var messages []Message
+	for _, call := range s.Calls() {
+		if !include(call) {
+			continue
+		}
+
+		switch {
+		case call.Response != nil && call.Response.Type == discordgo.InteractionResponseChannelMessageWithSource:
+			data := call.Response.Data
+			messages = append(messages, Message{
+				Content:    data.Content,
+				Embeds:     data.Embeds,
+				Components: data.Components,
+				Ephemeral:  data.Flags&discordgo.MessageFlagsEphemeral != 0,
+			})
+		case call.Edit != nil:
+			message := Message{}
+			if call.Edit.Content != nil {
+				message.Content = *call.Edit.Content
+			}
+			if call.Edit.Embeds != nil {
+				message.Embeds = *call.Edit.Embeds
+			}
+			if call.Edit.Components != nil {
+				message.Components = *call.Edit.Components
+			}
+			messages = append(messages, message)
+		case call.FollowUp != nil:
+			messages = append(messages, Message{
+				Content:    call.FollowUp.Content,
+				Embeds:     call.FollowUp.Embeds,
+				Components: call.FollowUp.Components,
+				Ephemeral:  call.FollowUp.Flags&discordgo.MessageFlagsEphemeral != 0,
+			})
+		}
+	}
+
+	return messages
+}
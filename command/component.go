@@ -0,0 +1,53 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// customIdSeparator separates the key of a custom ID from its arguments. Discord allows up to 100 characters in total.
+const customIdSeparator = ":"
+
+// ComponentHandler handles clicks on message components (like buttons) and submissions of modals. args are whatever was
+// encoded in the custom ID after its key - see CustomId. Errors are treated like they are for MessageHandler.
+type ComponentHandler interface {
+	HandleComponent(req *Request, args []string) (*Response, error)
+}
+
+// MessageComponent routes every component or modal whose custom ID has Key to Handler.
+type MessageComponent struct {
+	Handler ComponentHandler
+	Key     string
+}
+
+// CustomId builds a custom ID for a component or modal that will be routed to the MessageComponent with key, along
+// with args.
+func CustomId(key string, args ...string) string {
+	return strings.Join(append([]string{key}, args...), customIdSeparator)
+}
+
+// ParseCustomId splits a custom ID built by CustomId back into its key and args.
+func ParseCustomId(customId string) (string, []string) {
+	parts := strings.Split(customId, customIdSeparator)
+	return parts[0], parts[1:]
+}
+
+// ModalValues collects the values of every text input in a submitted modal, keyed by their custom IDs.
+func ModalValues(data discordgo.ModalSubmitInteractionData) map[string]string {
+	values := map[string]string{}
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+
+		for _, rowComponent := range row.Components {
+			if input, ok := rowComponent.(*discordgo.TextInput); ok {
+				values[input.CustomID] = input.Value
+			}
+		}
+	}
+
+	return values
+}
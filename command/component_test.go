@@ -0,0 +1,41 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomId(t *testing.T) {
+	t.Run("round trips", func(t *testing.T) {
+		key, args := ParseCustomId(CustomId("key", "first", "second"))
+		assert.Equal(t, "key", key)
+		assert.Equal(t, []string{"first", "second"}, args)
+	})
+
+	t.Run("no args", func(t *testing.T) {
+		key, args := ParseCustomId(CustomId("key"))
+		assert.Equal(t, "key", key)
+		assert.Empty(t, args)
+	})
+}
+
+func TestModalValues(t *testing.T) {
+	data := discordgo.ModalSubmitInteractionData{
+		Components: []discordgo.MessageComponent{
+			&discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					&discordgo.TextInput{CustomID: "first", Value: "1"},
+				},
+			},
+			&discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					&discordgo.TextInput{CustomID: "second", Value: "2"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]string{"first": "1", "second": "2"}, ModalValues(data))
+}
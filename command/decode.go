@@ -0,0 +1,223 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// optionTag names the option a struct field is decoded from - see Request.Decode.
+const optionTag = "option"
+
+// optionTypes describe what each type of option has to be, for validation errors.
+var optionTypes = map[discordgo.ApplicationCommandOptionType]string{
+	discordgo.ApplicationCommandOptionString:      "text",
+	discordgo.ApplicationCommandOptionInteger:     "a whole number",
+	discordgo.ApplicationCommandOptionNumber:      "a number",
+	discordgo.ApplicationCommandOptionBoolean:     "true or false",
+	discordgo.ApplicationCommandOptionUser:        "a user",
+	discordgo.ApplicationCommandOptionChannel:     "a channel",
+	discordgo.ApplicationCommandOptionRole:        "a role",
+	discordgo.ApplicationCommandOptionMentionable: "a user or role",
+}
+
+// ValidationError is returned by Request.Decode when an option doesn't match its definition. Its message is meant to be
+// shown to whoever used the command.
+type ValidationError struct {
+	Option string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("`%s` %s", e.Option, e.Reason)
+}
+
+// Decode fills the fields of the struct dst points to from the request's options, checking them against the command's
+// option definitions first - whether they're required, their min and max values and lengths, and their choices.
+//
+// Fields are matched to options with an `option:"name"` tag. They can be strings (which also hold the IDs of user,
+// channel, role and mentionable options), bools, ints or floats. Fields for optional options can be pointers, which are
+// left nil if the option wasn't passed; otherwise they're left as they were.
+func (r *Request) Decode(dst any) error {
+	if r.Command == nil {
+		return errors.New("request has no command to decode options for")
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("can't decode options into %T, it has to be a pointer to a struct", dst)
+	}
+
+	definitions := make(map[string]*discordgo.ApplicationCommandOption, len(r.Command.Options))
+	for _, definition := range r.Command.Options {
+		definitions[definition.Name] = definition
+	}
+
+	for name := range r.Options {
+		if _, ok := definitions[name]; !ok {
+			return &ValidationError{Option: name, Reason: "isn't an option I know about"}
+		}
+	}
+
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, ok := field.Tag.Lookup(optionTag)
+		if !ok {
+			continue
+		}
+
+		definition, ok := definitions[name]
+		if !ok {
+			return fmt.Errorf("field %s is for option %s, which /%s doesn't have", field.Name, name, r.Command.Name)
+		}
+
+		option, ok := r.Options[name]
+		if !ok {
+			if definition.Required {
+				return &ValidationError{Option: name, Reason: "is required"}
+			}
+			continue
+		}
+
+		value, err := validateOption(definition, option)
+		if err != nil {
+			return err
+		}
+
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("can't decode option %s into field %s: %w", name, field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateOption checks option against its definition, returning its value as a string, bool or float64.
+func validateOption(definition *discordgo.ApplicationCommandOption, option *discordgo.ApplicationCommandInteractionDataOption) (any, error) {
+	wrongType := &ValidationError{Option: definition.Name, Reason: "has to be " + optionTypes[definition.Type]}
+	if option.Type != definition.Type {
+		return nil, wrongType
+	}
+
+	var value any
+	switch definition.Type {
+	case discordgo.ApplicationCommandOptionString:
+		s, ok := option.Value.(string)
+		if !ok {
+			return nil, wrongType
+		}
+
+		length := utf8.RuneCountInString(s)
+		if definition.MinLength != nil && length < *definition.MinLength {
+			return nil, &ValidationError{Option: definition.Name, Reason: fmt.Sprintf("has to be at least %d character(s) long", *definition.MinLength)}
+		}
+		if definition.MaxLength != 0 && length > definition.MaxLength {
+			return nil, &ValidationError{Option: definition.Name, Reason: fmt.Sprintf("can't be more than %d character(s) long", definition.MaxLength)}
+		}
+		value = s
+	case discordgo.ApplicationCommandOptionInteger, discordgo.ApplicationCommandOptionNumber:
+		// Discord sends every number as JSON, so integers arrive as float64 too.
+		f, ok := option.Value.(float64)
+		if !ok || (definition.Type == discordgo.ApplicationCommandOptionInteger && f != math.Trunc(f)) {
+			return nil, wrongType
+		}
+
+		if definition.MinValue != nil && f < *definition.MinValue {
+			return nil, &ValidationError{Option: definition.Name, Reason: fmt.Sprintf("has to be at least %s", formatNumber(*definition.MinValue))}
+		}
+		// discordgo can't tell a max value of 0 from no max value at all.
+		if definition.MaxValue != 0 && f > definition.MaxValue {
+			return nil, &ValidationError{Option: definition.Name, Reason: fmt.Sprintf("can't be more than %s", formatNumber(definition.MaxValue))}
+		}
+		value = f
+	case discordgo.ApplicationCommandOptionBoolean:
+		b, ok := option.Value.(bool)
+		if !ok {
+			return nil, wrongType
+		}
+		value = b
+	case discordgo.ApplicationCommandOptionUser, discordgo.ApplicationCommandOptionChannel,
+		discordgo.ApplicationCommandOptionRole, discordgo.ApplicationCommandOptionMentionable:
+		id, ok := option.Value.(string)
+		if !ok {
+			return nil, wrongType
+		}
+		value = id
+	default:
+		return nil, fmt.Errorf("can't decode %s options", definition.Type)
+	}
+
+	if len(definition.Choices) > 0 && !slices.ContainsFunc(definition.Choices, func(choice *discordgo.ApplicationCommandOptionChoice) bool {
+		// Choices might be defined as ints, while the value is always a float64.
+		return formatValue(choice.Value) == formatValue(value)
+	}) {
+		return nil, &ValidationError{Option: definition.Name, Reason: "has to be one of the choices"}
+	}
+
+	return value, nil
+}
+
+// formatValue formats an option's value the way it'd be typed, so large numbers aren't in scientific notation.
+func formatValue(value any) string {
+	if f, ok := value.(float64); ok {
+		return formatNumber(f)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// formatNumber formats f without an exponent, like 5000000 instead of 5e+06.
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// setField sets field to value, converting it to the field's type.
+func setField(field reflect.Value, value any) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		if field.Kind() != reflect.String {
+			return fmt.Errorf("%s field needs a string", field.Type())
+		}
+		field.SetString(v)
+	case bool:
+		if field.Kind() != reflect.Bool {
+			return fmt.Errorf("%s field needs a bool", field.Type())
+		}
+		field.SetBool(v)
+	case float64:
+		switch {
+		case field.CanInt():
+			if field.OverflowInt(int64(v)) {
+				return fmt.Errorf("%v overflows %s", v, field.Type())
+			}
+			field.SetInt(int64(v))
+		case field.CanUint():
+			if v < 0 || field.OverflowUint(uint64(v)) {
+				return fmt.Errorf("%v overflows %s", v, field.Type())
+			}
+			field.SetUint(uint64(v))
+		case field.CanFloat():
+			field.SetFloat(v)
+		default:
+			return fmt.Errorf("%s field needs a number", field.Type())
+		}
+	}
+
+	return nil
+}
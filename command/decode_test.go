@@ -0,0 +1,235 @@
+package command
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	minCount    = float64(1)
+	minNameSize = 2
+
+	decodeCommand = &discordgo.ApplicationCommand{
+		Name: "decode",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:     discordgo.ApplicationCommandOptionString,
+				Name:     "flavour",
+				Required: true,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Vanilla", Value: "vanilla"},
+					{Name: "Chocolate", Value: "chocolate"},
+				},
+			},
+			{
+				Type:     discordgo.ApplicationCommandOptionInteger,
+				Name:     "count",
+				MinValue: &minCount,
+				MaxValue: 10,
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionNumber,
+				Name: "price",
+			},
+			{
+				Type:      discordgo.ApplicationCommandOptionString,
+				Name:      "name",
+				MinLength: &minNameSize,
+				MaxLength: 5,
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionBoolean,
+				Name: "sprinkles",
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionUser,
+				Name: "for",
+			},
+		},
+	}
+)
+
+type decodeOptions struct {
+	Flavour   string   `option:"flavour"`
+	Count     int      `option:"count"`
+	Price     *float64 `option:"price"`
+	Name      string   `option:"name"`
+	Sprinkles bool     `option:"sprinkles"`
+	For       string   `option:"for"`
+	Ignored   string
+}
+
+func decodeRequest(options ...*discordgo.ApplicationCommandInteractionDataOption) *Request {
+	req := &Request{Command: decodeCommand, Options: Options{}}
+	for _, option := range options {
+		req.Options[option.Name] = option
+	}
+
+	return req
+}
+
+func option(name string, optionType discordgo.ApplicationCommandOptionType, value interface{}) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: optionType, Value: value}
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("decodes every type", func(t *testing.T) {
+		req := decodeRequest(
+			option("flavour", discordgo.ApplicationCommandOptionString, "chocolate"),
+			option("count", discordgo.ApplicationCommandOptionInteger, float64(3)),
+			option("price", discordgo.ApplicationCommandOptionNumber, 2.5),
+			option("name", discordgo.ApplicationCommandOptionString, "Danny"),
+			option("sprinkles", discordgo.ApplicationCommandOptionBoolean, true),
+			option("for", discordgo.ApplicationCommandOptionUser, "someone"),
+		)
+
+		var options decodeOptions
+		require.NoError(t, req.Decode(&options))
+
+		price := 2.5
+		assert.Equal(t, decodeOptions{
+			Flavour:   "chocolate",
+			Count:     3,
+			Price:     &price,
+			Name:      "Danny",
+			Sprinkles: true,
+			For:       "someone",
+		}, options)
+	})
+
+	t.Run("leaves missing options alone", func(t *testing.T) {
+		req := decodeRequest(option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"))
+
+		options := decodeOptions{Count: 1}
+		require.NoError(t, req.Decode(&options))
+		assert.Equal(t, 1, options.Count)
+		assert.Nil(t, options.Price)
+	})
+
+	invalid := map[string]struct {
+		options []*discordgo.ApplicationCommandInteractionDataOption
+		err     ValidationError
+	}{
+		"missing required option": {
+			options: nil,
+			err:     ValidationError{Option: "flavour", Reason: "is required"},
+		},
+		"not a choice": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "strawberry"),
+			},
+			err: ValidationError{Option: "flavour", Reason: "has to be one of the choices"},
+		},
+		"wrong type": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"),
+				option("count", discordgo.ApplicationCommandOptionString, "three"),
+			},
+			err: ValidationError{Option: "count", Reason: "has to be a whole number"},
+		},
+		"wrong value type": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, 3),
+			},
+			err: ValidationError{Option: "flavour", Reason: "has to be text"},
+		},
+		"fraction for an integer": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"),
+				option("count", discordgo.ApplicationCommandOptionInteger, 1.5),
+			},
+			err: ValidationError{Option: "count", Reason: "has to be a whole number"},
+		},
+		"too small": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"),
+				option("count", discordgo.ApplicationCommandOptionInteger, float64(0)),
+			},
+			err: ValidationError{Option: "count", Reason: "has to be at least 1"},
+		},
+		"too big": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"),
+				option("count", discordgo.ApplicationCommandOptionInteger, float64(11)),
+			},
+			err: ValidationError{Option: "count", Reason: "can't be more than 10"},
+		},
+		"too short": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"),
+				option("name", discordgo.ApplicationCommandOptionString, "D"),
+			},
+			err: ValidationError{Option: "name", Reason: "has to be at least 2 character(s) long"},
+		},
+		"too long": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"),
+				option("name", discordgo.ApplicationCommandOptionString, "Daniel"),
+			},
+			err: ValidationError{Option: "name", Reason: "can't be more than 5 character(s) long"},
+		},
+		"unknown option": {
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("flavour", discordgo.ApplicationCommandOptionString, "vanilla"),
+				option("topping", discordgo.ApplicationCommandOptionString, "fudge"),
+			},
+			err: ValidationError{Option: "topping", Reason: "isn't an option I know about"},
+		},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var options decodeOptions
+			err := decodeRequest(test.options...).Decode(&options)
+
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, test.err, *validationErr)
+		})
+	}
+
+	t.Run("needs a struct pointer", func(t *testing.T) {
+		var options decodeOptions
+		err := decodeRequest(option("flavour", discordgo.ApplicationCommandOptionString, "vanilla")).Decode(options)
+		assert.Error(t, err)
+	})
+
+	t.Run("needs fields for real options", func(t *testing.T) {
+		var options struct {
+			Topping string `option:"topping"`
+		}
+		err := decodeRequest().Decode(&options)
+
+		var validationErr *ValidationError
+		assert.Error(t, err)
+		assert.False(t, errors.As(err, &validationErr), "a programming mistake isn't the user's fault")
+	})
+
+	t.Run("handles large numbers", func(t *testing.T) {
+		req := &Request{
+			Command: &discordgo.ApplicationCommand{Options: []*discordgo.ApplicationCommandOption{{
+				Type:     discordgo.ApplicationCommandOptionInteger,
+				Name:     "prize",
+				MaxValue: 5000000,
+				Choices:  []*discordgo.ApplicationCommandOptionChoice{{Name: "A million", Value: 1000000}},
+			}}},
+			Options: Options{"prize": option("prize", discordgo.ApplicationCommandOptionInteger, float64(1000000))},
+		}
+
+		var options struct {
+			Prize int `option:"prize"`
+		}
+		require.NoError(t, req.Decode(&options))
+		assert.Equal(t, 1000000, options.Prize)
+
+		req.Command.Options[0].Choices = nil
+		req.Options["prize"].Value = float64(5000001)
+		var validationErr *ValidationError
+		require.ErrorAs(t, req.Decode(&options), &validationErr)
+		assert.Equal(t, ValidationError{Option: "prize", Reason: "can't be more than 5000000"}, *validationErr)
+	})
+}
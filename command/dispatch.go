@@ -0,0 +1,324 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// DefaultTimeout is how long a deferred command's handler gets if its MessageCommand doesn't say.
+	DefaultTimeout = time.Minute
+
+	// DefaultContact is who people are told to get in touch with when something goes wrong, unless the Dispatcher's
+	// Contact says otherwise.
+	DefaultContact = "Danny"
+
+	// responseWindow is how long Discord waits for the first response to an interaction before giving up on it.
+	responseWindow = 3 * time.Second
+)
+
+// Dispatcher routes interactions to the handlers of the commands and components they were for, and sends their
+// responses back to Discord.
+type Dispatcher struct {
+	// Contact is who people are told to get in touch with when handling their interaction goes wrong.
+	Contact string
+
+	commands   map[string]MessageCommand
+	components map[string]ComponentHandler
+	// modules is the name of the module each command and component key belongs to.
+	modules    map[string]string
+	guilds     Guilds
+	middleware []Middleware
+
+	// lock guards draining, so nothing is added to inFlight once it's set.
+	lock     sync.Mutex
+	draining bool
+	inFlight sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher for the commands and components in modules, refusing any used in a guild where
+// their module isn't enabled. Every handler is called through middleware, outermost first. Without Recover, a
+// panicking handler takes the whole bot down with it.
+func NewDispatcher(modules []Module, guilds Guilds, middleware ...Middleware) *Dispatcher {
+	d := &Dispatcher{
+		Contact:    DefaultContact,
+		commands:   map[string]MessageCommand{},
+		components: map[string]ComponentHandler{},
+		modules:    map[string]string{},
+		guilds:     guilds,
+		middleware: middleware,
+	}
+
+	for _, module := range modules {
+		for _, command := range module.Commands {
+			d.commands[command.Key] = command
+			d.modules[command.Key] = module.Name
+		}
+		for _, component := range module.Components {
+			d.components[component.Key] = component.Handler
+			d.modules[component.Key] = module.Name
+		}
+	}
+
+	return d
+}
+
+// enabled returns whether the command or component with key can be used in guildId.
+func (d *Dispatcher) enabled(guildId, key string) bool {
+	return d.guilds.Enabled(guildId, d.modules[key])
+}
+
+// HandleInteraction is called when a person interacts with the bot via a command, button or modal, or is typing out a
+// command's options, and fires the relevant handler if present. It's meant to be added to a session with AddHandler.
+func (d *Dispatcher) HandleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	d.Dispatch(s, i.Interaction)
+}
+
+// Dispatch fires the handler for i and sends its responses through s, returning once they've all been sent.
+func (d *Dispatcher) Dispatch(s Session, i *discordgo.Interaction) {
+	if !d.begin() {
+		// Suggestions can't carry a message, so there's nothing better to do than not suggest anything.
+		if i.Type != discordgo.InteractionApplicationCommandAutocomplete {
+			respond(s, i, Ephemeral("I'm restarting! Try again in a minute."))
+		}
+		return
+	}
+	defer d.inFlight.Done()
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		d.handleCommand(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		d.handleAutocomplete(s, i)
+	case discordgo.InteractionMessageComponent:
+		d.handleComponent(s, i, i.MessageComponentData().CustomID)
+	case discordgo.InteractionModalSubmit:
+		d.handleComponent(s, i, i.ModalSubmitData().CustomID)
+	}
+}
+
+// begin counts an interaction as in flight, unless the dispatcher is draining.
+func (d *Dispatcher) begin() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.draining {
+		return false
+	}
+	d.inFlight.Add(1)
+
+	return true
+}
+
+// Drain stops the dispatcher from handling any more interactions, turning them away instead, and waits for the ones
+// it's already handling to finish - including handlers that were given up on for taking too long, which could still be
+// using storage. It gives up once ctx is done.
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	d.lock.Lock()
+	d.draining = true
+	d.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("interactions still in flight: %w", ctx.Err())
+	}
+}
+
+// handleCommand fires the handler for the slash command in i.
+func (d *Dispatcher) handleCommand(s Session, i *discordgo.Interaction) {
+	data := i.ApplicationCommandData()
+
+	if i.Member == nil {
+		respond(s, i, Ephemeral("Sorry, you can't use this bot in DMs."))
+		return
+	}
+
+	cmd, ok := d.commands[data.Name]
+	if !ok {
+		log.Printf("no handler for command %s", data.Name)
+		respond(s, i, Ephemeral("That command doesn't do anything anymore!"))
+		return
+	} else if !d.enabled(i.GuildID, data.Name) {
+		log.Printf("%s command used in guild %s, where it isn't enabled", data.Name, i.GuildID)
+		respond(s, i, Ephemeral("That command isn't enabled in this server!"))
+		return
+	}
+
+	cmd, name, options, err := resolve(cmd, data.Options)
+	if err != nil {
+		log.Printf("no handler for command: %v", err)
+		respond(s, i, Ephemeral("That command doesn't do anything anymore!"))
+		return
+	}
+
+	if !cmd.Deferred {
+		ctx, cancel := context.WithTimeout(context.Background(), responseWindow)
+		defer cancel()
+
+		respond(s, i, d.run(newRequest(ctx, i, name, cmd.CommandInfo, options), d.wrap(cmd.Handler.Handle)))
+		return
+	}
+
+	err = s.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Printf("Cannot defer command %s: %v", name, err)
+		return
+	}
+
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	d.respondDeferred(s, i, d.run(newRequest(ctx, i, name, cmd.CommandInfo, options), d.wrap(cmd.Handler.Handle)))
+}
+
+// handleComponent fires the handler for the button or modal with customId in i.
+func (d *Dispatcher) handleComponent(s Session, i *discordgo.Interaction, customId string) {
+	key, args := ParseCustomId(customId)
+
+	h, ok := d.components[key]
+	if i.Member == nil {
+		respond(s, i, Ephemeral("Sorry, you can't use this bot in DMs."))
+		return
+	} else if !ok {
+		log.Printf("no handler for component %s", customId)
+		respond(s, i, Ephemeral("That doesn't do anything anymore!"))
+		return
+	} else if !d.enabled(i.GuildID, key) {
+		log.Printf("%s component used in guild %s, where it isn't enabled", key, i.GuildID)
+		respond(s, i, Ephemeral("That isn't enabled in this server!"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), responseWindow)
+	defer cancel()
+
+	respond(s, i, d.run(newRequest(ctx, i, key, nil, nil), d.wrap(func(req *Request) (*Response, error) {
+		return h.HandleComponent(req, args)
+	})))
+}
+
+// wrap wraps handler in the dispatcher's middleware.
+func (d *Dispatcher) wrap(handler HandlerFunc) HandlerFunc {
+	for i := len(d.middleware) - 1; i >= 0; i-- {
+		handler = d.middleware[i](handler)
+	}
+
+	return handler
+}
+
+// run calls handle, giving up on it once the request's context is done unless it's committed to changing something -
+// see Request.Commit. Errors are logged and turned into a response.
+func (d *Dispatcher) run(req *Request, handle HandlerFunc) *Response {
+	type result struct {
+		response *Response
+		err      error
+	}
+
+	// The handler can outlive run if it's given up on, so it's waited for separately by Drain.
+	results := make(chan result, 1)
+	d.inFlight.Add(1)
+	go func() {
+		defer d.inFlight.Done()
+		response, err := handle(req)
+		if err == nil && response == nil {
+			err = errors.New("handler returned no response")
+		}
+		results <- result{response, err}
+	}()
+
+	var res result
+	select {
+	case res = <-results:
+	case <-req.Context.Done():
+		if req.abandon() {
+			res.err = req.Context.Err()
+		} else {
+			// Whatever the handler was doing is going through, so its response is the one to send.
+			res = <-results
+		}
+	}
+
+	var validationErr *ValidationError
+	if errors.As(res.err, &validationErr) {
+		log.Printf("%s rejected options from %s in guild %s: %v", req.Name, req.Member.User.ID, req.GuildID, res.err)
+		return Ephemeral(fmt.Sprintf("Hmm, %s!", validationErr))
+	} else if res.err != nil {
+		log.Printf("%s failed for %s in guild %s: %v", req.Name, req.Member.User.ID, req.GuildID, res.err)
+		return d.errorResponse(res.err)
+	}
+
+	return res.response
+}
+
+// errorResponse is what people see when handling their interaction failed.
+func (d *Dispatcher) errorResponse(err error) *Response {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Ephemeral(fmt.Sprintf("That took too long! Try again, and if it keeps happening please tell %s.", d.Contact))
+	}
+
+	return Ephemeral(fmt.Sprintf("Something went wrong! Try again, and if it keeps happening please tell %s.", d.Contact))
+}
+
+// respond sends response as the first response to interaction.
+func respond(s Session, interaction *discordgo.Interaction, response *Response) {
+	if err := s.InteractionRespond(interaction, interactionResponse(response)); err != nil {
+		log.Printf("Cannot respond to interaction %s: %v", interaction.ID, err)
+		return
+	}
+
+	sendFollowUps(s, interaction, response.FollowUps)
+}
+
+// respondDeferred replaces the "thinking..." message of a deferred interaction with response.
+func (d *Dispatcher) respondDeferred(s Session, interaction *discordgo.Interaction, response *Response) {
+	if response.Modal != nil {
+		log.Printf("deferred interaction %s tried to open a modal", interaction.ID)
+		response = Ephemeral(fmt.Sprintf("Something fucky's going on if you're getting this response. Please tell %s.", d.Contact))
+	}
+
+	if response.Ephemeral {
+		// A deferred response is already visible to everyone, so the only way to hide it is to swap it for a
+		// follow-up.
+		if err := s.InteractionResponseDelete(interaction); err != nil {
+			log.Printf("Cannot delete response to interaction %s: %v", interaction.ID, err)
+		}
+		sendFollowUps(s, interaction, append([]*Response{response}, response.FollowUps...))
+		return
+	}
+
+	if _, err := s.InteractionResponseEdit(interaction, webhookEdit(response)); err != nil {
+		log.Printf("Cannot edit response to interaction %s: %v", interaction.ID, err)
+		return
+	}
+
+	sendFollowUps(s, interaction, response.FollowUps)
+}
+
+func sendFollowUps(s Session, interaction *discordgo.Interaction, followUps []*Response) {
+	for _, followUp := range followUps {
+		if _, err := s.FollowupMessageCreate(interaction, false, webhookParams(followUp)); err != nil {
+			log.Printf("Cannot send follow-up to interaction %s: %v", interaction.ID, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	newRequest := func(ctx context.Context, name string) *Request {
+		return &Request{Name: name, Context: ctx, GuildID: "guild", Member: &discordgo.Member{User: &discordgo.User{ID: "player"}}}
+	}
+	d := NewDispatcher(nil, nil)
+
+	t.Run("returns the handler's response", func(t *testing.T) {
+		response := d.run(newRequest(context.Background(), "/fast"), func(req *Request) (*Response, error) {
+			return Message("done"), nil
+		})
+
+		assert.Equal(t, Message("done"), response)
+	})
+
+	t.Run("hides errors", func(t *testing.T) {
+		response := d.run(newRequest(context.Background(), "/broken"), func(req *Request) (*Response, error) {
+			return nil, errors.New("secret database details")
+		})
+
+		assert.True(t, response.Ephemeral)
+		assert.NotContains(t, response.Content, "secret")
+		assert.Contains(t, response.Content, "Something went wrong")
+		assert.Contains(t, response.Content, "please tell Danny")
+	})
+
+	t.Run("tells people who to contact", func(t *testing.T) {
+		d := NewDispatcher(nil, nil)
+		d.Contact = "the mods"
+
+		response := d.run(newRequest(context.Background(), "/broken"), func(req *Request) (*Response, error) {
+			return nil, errors.New("oops")
+		})
+
+		assert.Contains(t, response.Content, "please tell the mods")
+	})
+
+	t.Run("needs a response", func(t *testing.T) {
+		response := d.run(newRequest(context.Background(), "/empty"), func(req *Request) (*Response, error) {
+			return nil, nil
+		})
+
+		assert.Contains(t, response.Content, "Something went wrong")
+	})
+
+	t.Run("gives up once the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		response := d.run(newRequest(ctx, "/slow"), func(req *Request) (*Response, error) {
+			<-req.Context.Done()
+			time.Sleep(10 * time.Millisecond)
+			return Message("too late"), nil
+		})
+
+		assert.True(t, response.Ephemeral)
+		assert.Contains(t, response.Content, "too long")
+	})
+
+	t.Run("doesn't let a handler it gave up on change anything", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		committed := make(chan error, 1)
+		response := d.run(newRequest(ctx, "/slow"), func(req *Request) (*Response, error) {
+			// Most handlers don't watch their context.
+			time.Sleep(30 * time.Millisecond)
+			err := req.Commit()
+			committed <- err
+			return Message("saved"), err
+		})
+
+		assert.Contains(t, response.Content, "too long")
+		assert.ErrorIs(t, <-committed, context.DeadlineExceeded)
+	})
+
+	t.Run("waits for a handler that's committed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		response := d.run(newRequest(ctx, "/slow"), func(req *Request) (*Response, error) {
+			if err := req.Commit(); err != nil {
+				return nil, err
+			}
+			time.Sleep(30 * time.Millisecond)
+			return Message("saved"), nil
+		})
+
+		assert.Equal(t, Message("saved"), response)
+	})
+}
+
+func TestInteractionResponse(t *testing.T) {
+	t.Run("message", func(t *testing.T) {
+		response := interactionResponse(&Response{Content: "hi", Ephemeral: true, AllowedMentions: NoMentions()})
+
+		assert.Equal(t, discordgo.InteractionResponseChannelMessageWithSource, response.Type)
+		assert.Equal(t, "hi", response.Data.Content)
+		assert.Equal(t, discordgo.MessageFlagsEphemeral, response.Data.Flags)
+		assert.Empty(t, response.Data.AllowedMentions.Parse)
+	})
+
+	t.Run("modal", func(t *testing.T) {
+		response := interactionResponse(&Response{Content: "ignored", Modal: &Modal{CustomID: "modal", Title: "Title"}})
+
+		assert.Equal(t, discordgo.InteractionResponseModal, response.Type)
+		assert.Equal(t, "modal", response.Data.CustomID)
+		assert.Equal(t, "Title", response.Data.Title)
+		assert.Empty(t, response.Data.Content)
+	})
+}
+
+func TestWebhookParams(t *testing.T) {
+	params := webhookParams(Ephemeral("psst"))
+	assert.Equal(t, "psst", params.Content)
+	assert.Equal(t, discordgo.MessageFlagsEphemeral, params.Flags)
+
+	params = webhookParams(Message("hey"))
+	assert.Zero(t, params.Flags)
+}
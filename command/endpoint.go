@@ -0,0 +1,153 @@
+package command
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxInteractionSize is the most an Endpoint reads of a request. Interactions are much smaller, and anything bigger
+// is read before its signature can be checked.
+const maxInteractionSize = 1 << 20
+
+// Endpoint serves Discord's HTTP interactions webhook, so the bot can receive interactions without keeping a gateway
+// connection open. Requests that aren't signed with the application's public key are refused, like Discord requires.
+type Endpoint struct {
+	dispatcher *Dispatcher
+	publicKey  ed25519.PublicKey
+	session    Session
+}
+
+// NewEndpoint creates an Endpoint dispatching interactions signed with publicKey to dispatcher. The first response to
+// each interaction is sent back as the HTTP response. Anything after it, like follow-ups and the edits of deferred
+// responses, is sent through session.
+func NewEndpoint(dispatcher *Dispatcher, publicKey ed25519.PublicKey, session Session) *Endpoint {
+	return &Endpoint{
+		dispatcher: dispatcher,
+		publicKey:  publicKey,
+		session:    session,
+	}
+}
+
+func (e *Endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInteractionSize)
+
+	// Discord checks this is done by sending badly signed requests every now and then.
+	if !discordgo.VerifyInteraction(r, e.publicKey) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var interaction discordgo.Interaction
+	if err := json.NewDecoder(r.Body).Decode(&interaction); err != nil {
+		log.Printf("Cannot read interaction: %v", err)
+		http.Error(w, "invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	if interaction.Type == discordgo.InteractionPing {
+		writeInteractionResponse(w, &discordgo.InteractionResponse{Type: discordgo.InteractionResponsePong})
+		return
+	}
+
+	s := &httpSession{
+		Session:   e.session,
+		first:     make(chan *discordgo.InteractionResponse, 1),
+		responded: make(chan struct{}),
+	}
+	defer close(s.responded)
+
+	// Deferred commands carry on after their first response, so they can't hold up the HTTP response.
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		e.dispatcher.Dispatch(s, &interaction)
+	}()
+
+	select {
+	case response := <-s.first:
+		writeInteractionResponse(w, response)
+	case <-dispatched:
+		select {
+		case response := <-s.first:
+			writeInteractionResponse(w, response)
+		default:
+			log.Printf("interaction %s was dispatched without a response", interaction.ID)
+			http.Error(w, "no response", http.StatusInternalServerError)
+		}
+	case <-r.Context().Done():
+		log.Printf("Gave up on responding to interaction %s: %v", interaction.ID, r.Context().Err())
+	}
+}
+
+func writeInteractionResponse(w http.ResponseWriter, response *discordgo.InteractionResponse) {
+	var contentType string
+	var body []byte
+	var err error
+	if response.Data != nil && len(response.Data.Files) > 0 {
+		contentType, body, err = discordgo.MultipartBodyWithJSON(response, response.Data.Files)
+	} else {
+		contentType = "application/json"
+		body, err = json.Marshal(response)
+	}
+	if err != nil {
+		log.Printf("Cannot encode interaction response: %v", err)
+		http.Error(w, "can't encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Cannot send interaction response: %v", err)
+		return
+	}
+	// Send it now, so Discord has it before anything sent through the Session after it.
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// httpSession is the Session for an interaction received by an Endpoint. Its first response is handed back to be sent
+// as the HTTP response, and everything after it goes through the embedded Session once that has been sent.
+type httpSession struct {
+	Session
+
+	first chan *discordgo.InteractionResponse
+	// responded is closed once the HTTP response has been sent, or given up on.
+	responded  chan struct{}
+	handedBack atomic.Bool
+}
+
+func (s *httpSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	if s.handedBack.CompareAndSwap(false, true) {
+		s.first <- resp
+		return nil
+	}
+
+	<-s.responded
+	return s.Session.InteractionRespond(interaction, resp, options...)
+}
+
+func (s *httpSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	<-s.responded
+	return s.Session.InteractionResponseEdit(interaction, newresp, options...)
+}
+
+func (s *httpSession) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	<-s.responded
+	return s.Session.InteractionResponseDelete(interaction, options...)
+}
+
+func (s *httpSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	<-s.responded
+	return s.Session.FollowupMessageCreate(interaction, wait, data, options...)
+}
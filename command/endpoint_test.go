@@ -0,0 +1,137 @@
+package command_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/command/commandtest"
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type endpointHandler struct {
+	response *command.Response
+}
+
+func (h endpointHandler) Handle(req *command.Request) (*command.Response, error) {
+	return h.response, nil
+}
+
+// newEndpoint serves an endpoint for a few commands, returning a client to send it interactions and the session
+// everything after the first response of each is sent through.
+func newEndpoint(t *testing.T) (*commandtest.Client, *commandtest.Session) {
+	cmd := func(key string, response *command.Response, deferred bool) command.MessageCommand {
+		return command.MessageCommand{
+			CommandInfo: &discordgo.ApplicationCommand{Name: key, Description: key},
+			Handler:     endpointHandler{response},
+			Key:         key,
+			Deferred:    deferred,
+		}
+	}
+	dispatcher := command.NewDispatcher([]command.Module{{
+		Name: "test",
+		Commands: []command.MessageCommand{
+			cmd("hello", &command.Response{
+				Content:    "hi!",
+				Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.Button{Label: "Wave", CustomID: "wave"}}}},
+				FollowUps:  []*command.Response{command.Ephemeral("psst")},
+			}, false),
+			cmd("slow", command.Message("done"), true),
+		},
+	}}, nil)
+
+	session := &commandtest.Session{}
+	server := httptest.NewServer(nil)
+	t.Cleanup(server.Close)
+
+	client, err := commandtest.NewClient(server.URL)
+	require.NoError(t, err)
+	server.Config.Handler = command.NewEndpoint(dispatcher, client.PublicKey(), session)
+
+	return client, session
+}
+
+func TestEndpointPing(t *testing.T) {
+	client, session := newEndpoint(t)
+
+	response, err := client.Send(&discordgo.Interaction{ID: "1", Type: discordgo.InteractionPing})
+	require.NoError(t, err)
+	assert.Equal(t, discordgo.InteractionResponsePong, response.Type)
+	assert.Empty(t, session.Calls())
+}
+
+func TestEndpointCommand(t *testing.T) {
+	client, session := newEndpoint(t)
+
+	response, err := client.Send(commandtest.As("alice", commandtest.Command("hello")).Interaction)
+	require.NoError(t, err)
+	assert.Equal(t, discordgo.InteractionResponseChannelMessageWithSource, response.Type)
+	assert.Equal(t, "hi!", response.Data.Content)
+	require.Len(t, response.Data.Components, 1)
+	assert.Equal(t, "wave", response.Data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.Button).CustomID)
+
+	// Follow-ups go through the session once the response has been sent.
+	assert.Eventually(t, func() bool { return len(session.Messages()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []commandtest.Message{{Content: "psst", Ephemeral: true}}, session.Messages())
+}
+
+func TestEndpointDeferredCommand(t *testing.T) {
+	client, session := newEndpoint(t)
+
+	response, err := client.Send(commandtest.As("alice", commandtest.Command("slow")).Interaction)
+	require.NoError(t, err)
+	assert.Equal(t, discordgo.InteractionResponseDeferredChannelMessageWithSource, response.Type)
+
+	assert.Eventually(t, func() bool { return len(session.Messages()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, "InteractionResponseEdit", session.Calls()[0].Method)
+	assert.Equal(t, []commandtest.Message{{Content: "done"}}, session.Messages())
+}
+
+func TestEndpointRejects(t *testing.T) {
+	client, session := newEndpoint(t)
+	ping := []byte(`{"id":"1","type":1}`)
+	// Still a ping, but padded out past what's read.
+	huge := append(bytes.Repeat([]byte(" "), 2<<20), ping...)
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		method string
+		body   []byte
+		// signed is what the signature is for, or nil for no signature.
+		signed []byte
+		key    ed25519.PrivateKey
+		status int
+	}{
+		"unsigned":                {http.MethodPost, ping, nil, nil, http.StatusUnauthorized},
+		"signed with another key": {http.MethodPost, ping, ping, otherKey, http.StatusUnauthorized},
+		"tampered with":           {http.MethodPost, ping, []byte(`{"id":"2","type":1}`), client.Key, http.StatusUnauthorized},
+		"not a post":              {http.MethodGet, ping, ping, client.Key, http.StatusMethodNotAllowed},
+		"not an interaction":      {http.MethodPost, []byte("nope"), []byte("nope"), client.Key, http.StatusBadRequest},
+		"too big":                 {http.MethodPost, huge, huge, client.Key, http.StatusUnauthorized},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, client.URL, bytes.NewReader(test.body))
+			require.NoError(t, err)
+			if test.signed != nil {
+				commandtest.Sign(req, test.signed, test.key)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, test.status, resp.StatusCode)
+		})
+	}
+
+	assert.Empty(t, session.Calls())
+}
@@ -0,0 +1,150 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxMessageLength is the most characters Discord allows in a message.
+const maxMessageLength = 2000
+
+// HandlerFunc handles a request. Every command and component handler is called through one, so they can be wrapped in
+// Middleware.
+type HandlerFunc func(req *Request) (*Response, error)
+
+// Middleware wraps a handler to do something before or after it runs, like logging or recovering from panics.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// PanicReporter is told about every panic Recover recovers from, with the stack trace of where it happened.
+type PanicReporter func(req *Request, recovered any, stack []byte)
+
+// Recover stops a panicking handler from taking down the bot. The panic is logged with its stack trace, passed to
+// every reporter, and returned as an error so the person who used the command is still told something went wrong.
+func Recover(reporters ...PanicReporter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) (response *Response, err error) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+
+				stack := debug.Stack()
+				log.Printf("%s panicked: %v\n%s", req.Name, recovered, stack)
+				for _, report := range reporters {
+					report(req, recovered, stack)
+				}
+
+				response, err = nil, fmt.Errorf("panic: %v", recovered)
+			}()
+
+			return next(req)
+		}
+	}
+}
+
+// ReportToChannel sends panics to a channel, so whoever looks after the bot hears about them without digging
+// through logs.
+func ReportToChannel(s *discordgo.Session, channelId string) PanicReporter {
+	return func(req *Request, recovered any, stack []byte) {
+		header := fmt.Sprintf("%s panicked for %s in guild `%s`: %v\n", req.Name, req.Member.User.Mention(), req.GuildID, recovered)
+		trace := []rune(string(stack))
+		if room := maxMessageLength - len([]rune(header)) - len("```\n```"); len(trace) > room {
+			trace = trace[:max(room, 0)]
+		}
+
+		_, err := s.ChannelMessageSendComplex(channelId, &discordgo.MessageSend{
+			Content:         header + "```\n" + string(trace) + "```",
+			AllowedMentions: NoMentions(),
+		})
+		if err != nil {
+			log.Printf("Cannot report panic to channel %s: %v", channelId, err)
+		}
+	}
+}
+
+// Logging logs everything the bot is asked to handle, and who by.
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) (*Response, error) {
+			log.Printf("%s used by %s in guild %s", req.Name, req.Member.User.Username, req.GuildID)
+			return next(req)
+		}
+	}
+}
+
+// Timing logs every handler that takes longer than slow.
+func Timing(slow time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) (*Response, error) {
+			start := time.Now()
+			defer func() {
+				if elapsed := time.Since(start); elapsed > slow {
+					log.Printf("%s took %v", req.Name, elapsed)
+				}
+			}()
+
+			return next(req)
+		}
+	}
+}
+
+// Limiter decides whether a request is let through to its handler, e.g. to stop one person spamming a command.
+type Limiter interface {
+	Allow(req *Request) bool
+}
+
+// RateLimit turns away every request limiter doesn't allow.
+func RateLimit(limiter Limiter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(req *Request) (*Response, error) {
+			if !limiter.Allow(req) {
+				return Ephemeral("Slow down! Try again in a bit."), nil
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// Cooldown is a Limiter letting each member use each command or component once every period.
+type Cooldown struct {
+	period time.Duration
+	now    func() time.Time
+
+	lock     sync.Mutex
+	lastUsed map[string]time.Time
+}
+
+func NewCooldown(period time.Duration) *Cooldown {
+	return &Cooldown{
+		period:   period,
+		now:      time.Now,
+		lastUsed: map[string]time.Time{},
+	}
+}
+
+func (c *Cooldown) Allow(req *Request) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	for key, lastUsed := range c.lastUsed {
+		if now.Sub(lastUsed) >= c.period {
+			delete(c.lastUsed, key)
+		}
+	}
+
+	key := req.GuildID + "/" + req.Member.User.ID + "/" + req.Name
+	if _, ok := c.lastUsed[key]; ok {
+		return false
+	}
+
+	c.lastUsed[key] = now
+	return true
+}
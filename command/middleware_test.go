@@ -0,0 +1,89 @@
+package command
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func middlewareRequest(name, playerId string) *Request {
+	return &Request{Name: name, GuildID: "guild", Member: &discordgo.Member{User: &discordgo.User{ID: playerId}}}
+}
+
+func TestRecover(t *testing.T) {
+	var reported any
+	handler := Recover(func(req *Request, recovered any, stack []byte) {
+		reported = recovered
+		assert.Contains(t, string(stack), "middleware_test.go")
+	})(func(req *Request) (*Response, error) {
+		var options map[string]interface{}
+		return Message(options["missing"].(string)), nil
+	})
+
+	response, err := handler(middlewareRequest("/panicky", "player"))
+	assert.Nil(t, response)
+	assert.ErrorContains(t, err, "panic")
+
+	var runtimeErr interface{ RuntimeError() }
+	assert.True(t, errors.As(reported.(error), &runtimeErr))
+}
+
+func TestRecoverPassesThrough(t *testing.T) {
+	handler := Recover()(func(req *Request) (*Response, error) {
+		return Message("fine"), nil
+	})
+
+	response, err := handler(middlewareRequest("/fine", "player"))
+	assert.NoError(t, err)
+	assert.Equal(t, Message("fine"), response)
+}
+
+func TestDispatcherMiddlewareOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(req *Request) (*Response, error) {
+				calls = append(calls, name)
+				return next(req)
+			}
+		}
+	}
+
+	d := NewDispatcher(nil, nil, record("outer"), record("inner"))
+	_, err := d.wrap(func(req *Request) (*Response, error) {
+		calls = append(calls, "handler")
+		return Message("done"), nil
+	})(middlewareRequest("/ordered", "player"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner", "handler"}, calls)
+}
+
+func TestCooldown(t *testing.T) {
+	now := time.Unix(0, 0)
+	cooldown := NewCooldown(time.Second)
+	cooldown.now = func() time.Time { return now }
+
+	handler := RateLimit(cooldown)(func(req *Request) (*Response, error) {
+		return Message("done"), nil
+	})
+
+	response, err := handler(middlewareRequest("/spam", "player"))
+	require.NoError(t, err)
+	assert.Equal(t, Message("done"), response)
+
+	response, err = handler(middlewareRequest("/spam", "player"))
+	require.NoError(t, err)
+	assert.True(t, response.Ephemeral)
+	assert.Contains(t, response.Content, "Slow down")
+
+	assert.True(t, cooldown.Allow(middlewareRequest("/spam", "someone else")))
+	assert.True(t, cooldown.Allow(middlewareRequest("/other", "player")))
+
+	now = now.Add(time.Second)
+	assert.True(t, cooldown.Allow(middlewareRequest("/spam", "player")))
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Module is a set of commands and components that are enabled or disabled together, like MDB.
+type Module struct {
+	Name       string
+	Commands   []MessageCommand
+	Components []MessageComponent
+}
+
+// ApplicationCommands returns the definitions of every command in modules, for registering with Discord.
+func ApplicationCommands(modules []Module) []*discordgo.ApplicationCommand {
+	var commands []*discordgo.ApplicationCommand
+	for _, module := range modules {
+		for _, cmd := range module.Commands {
+			commands = append(commands, cmd.CommandInfo)
+		}
+	}
+
+	return commands
+}
+
+// Guilds says which modules are enabled in which guilds, by guild ID. A nil Guilds enables every module everywhere.
+type Guilds map[string][]string
+
+// Enabled returns whether module can be used in guildId.
+func (g Guilds) Enabled(guildId, module string) bool {
+	if g == nil {
+		return true
+	}
+
+	return slices.Contains(g[guildId], module)
+}
+
+// Modules returns the modules out of modules that are enabled in guildId.
+func (g Guilds) Modules(guildId string, modules []Module) []Module {
+	var enabled []Module
+	for _, module := range modules {
+		if g.Enabled(guildId, module.Name) {
+			enabled = append(enabled, module)
+		}
+	}
+
+	return enabled
+}
+
+// ParseGuilds reads which modules are enabled in which guilds from a comma-separated list like `123=mdb+other,456`, as
+// passed to -guilds. Each guild ID can be followed by the modules enabled in it, separated by `+`; a guild ID on its own
+// gets an empty list, enabling every module - see NewGuilds. An empty list returns nil.
+func ParseGuilds(list string) (map[string][]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	enabled := map[string][]string{}
+	for _, entry := range strings.Split(list, ",") {
+		guildId, modules, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if guildId == "" {
+			return nil, fmt.Errorf("%q is missing a guild ID", entry)
+		} else if _, ok := enabled[guildId]; ok {
+			return nil, fmt.Errorf("guild %s is listed twice", guildId)
+		}
+
+		enabled[guildId] = []string{}
+		if found {
+			enabled[guildId] = strings.Split(modules, "+")
+		}
+	}
+
+	return enabled, nil
+}
+
+// NewGuilds creates Guilds from the names of the modules enabled in each guild, checking they're all in modules. A
+// guild with no modules listed has every module in modules enabled. Without any guilds, it returns nil, enabling every
+// module everywhere.
+func NewGuilds(enabled map[string][]string, modules []Module) (Guilds, error) {
+	if len(enabled) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(modules))
+	for _, module := range modules {
+		names = append(names, module.Name)
+	}
+
+	guilds := Guilds{}
+	for guildId, enabledNames := range enabled {
+		if guildId == "" {
+			return nil, errors.New("a guild is missing its ID")
+		}
+
+		if len(enabledNames) == 0 {
+			guilds[guildId] = names
+			continue
+		}
+
+		guilds[guildId] = []string{}
+		for _, name := range enabledNames {
+			if !slices.Contains(names, name) {
+				return nil, fmt.Errorf("guild %s enables unknown module %q", guildId, name)
+			}
+			guilds[guildId] = append(guilds[guildId], name)
+		}
+	}
+
+	return guilds, nil
+}
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testModules = []Module{
+	{Name: "mdb", Commands: []MessageCommand{stubCommand("mdb")}, Components: []MessageComponent{{Key: "mdb-button"}}},
+	{Name: "other", Commands: []MessageCommand{stubCommand("other")}},
+}
+
+func TestParseGuilds(t *testing.T) {
+	t.Run("nothing", func(t *testing.T) {
+		enabled, err := ParseGuilds(" ")
+		require.NoError(t, err)
+		assert.Nil(t, enabled)
+	})
+
+	t.Run("modules per guild", func(t *testing.T) {
+		enabled, err := ParseGuilds("1=mdb, 2=mdb+other,3")
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			"1": {"mdb"},
+			"2": {"mdb", "other"},
+			"3": {},
+		}, enabled)
+	})
+
+	for name, list := range map[string]string{
+		"missing guild ID": "=mdb",
+		"listed twice":     "1=mdb,1=other",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseGuilds(list)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewGuilds(t *testing.T) {
+	t.Run("everything everywhere", func(t *testing.T) {
+		guilds, err := NewGuilds(nil, testModules)
+		require.NoError(t, err)
+		assert.Nil(t, guilds)
+		assert.True(t, guilds.Enabled("any guild", "mdb"))
+	})
+
+	t.Run("modules per guild", func(t *testing.T) {
+		guilds, err := NewGuilds(map[string][]string{
+			"1": {"mdb"},
+			"2": {"mdb", "other"},
+			"3": nil,
+		}, testModules)
+		require.NoError(t, err)
+		assert.Equal(t, Guilds{
+			"1": {"mdb"},
+			"2": {"mdb", "other"},
+			"3": {"mdb", "other"},
+		}, guilds)
+
+		assert.True(t, guilds.Enabled("1", "mdb"))
+		assert.False(t, guilds.Enabled("1", "other"))
+		assert.False(t, guilds.Enabled("4", "mdb"))
+	})
+
+	for name, enabled := range map[string]map[string][]string{
+		"unknown module":   {"1": {"mdb", "games"}},
+		"missing guild ID": {"": {"mdb"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewGuilds(enabled, testModules)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGuildModules(t *testing.T) {
+	guilds := Guilds{"1": {"other"}}
+
+	enabled := guilds.Modules("1", testModules)
+	require.Len(t, enabled, 1)
+	assert.Equal(t, "other", enabled[0].Name)
+
+	commands := ApplicationCommands(enabled)
+	require.Len(t, commands, 1)
+	assert.Equal(t, "other", commands[0].Name)
+
+	assert.Empty(t, guilds.Modules("2", testModules))
+	assert.Len(t, Guilds(nil).Modules("2", testModules), 2)
+}
+
+func TestDispatcherEnabled(t *testing.T) {
+	d := NewDispatcher(testModules, Guilds{"1": {"mdb"}})
+
+	assert.True(t, d.enabled("1", "mdb"))
+	assert.True(t, d.enabled("1", "mdb-button"))
+	assert.False(t, d.enabled("1", "other"))
+	assert.False(t, d.enabled("2", "mdb"))
+	assert.False(t, d.enabled("2", "mdb-button"))
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Request is everything a handler gets to know about the interaction it's handling.
+type Request struct {
+	// Name is what's being handled - the name of a slash command, like /answer, or the key of a component.
+	Name string
+	// Context is cancelled once the handler has run out of time to respond - see MessageCommand. Handlers should call
+	// Commit before changing anything, so nothing changes once they've been given up on.
+	Context   context.Context
+	GuildID   string
+	ChannelID string
+	Member    *discordgo.Member
+	Locale    discordgo.Locale
+	// Command is the definition of the slash command being handled. Nil for components and modals.
+	Command *discordgo.ApplicationCommand
+	// Options are the options a slash command was used with. Empty for components and modals. Read them with Decode.
+	Options Options
+
+	// Interaction is the raw interaction, for anything not covered above.
+	Interaction *discordgo.Interaction
+
+	// commitLock guards committed, so a handler can't commit once it's been given up on, and isn't given up on once
+	// it's committed.
+	commitLock sync.Mutex
+	committed  bool
+}
+
+// Commit is called by a handler right before it changes anything, like saving an answer. It returns an error once the
+// handler has run out of time, since the person who used the command is told it failed - so nothing should change.
+// Once committed, the handler is no longer given up on, and whatever it responds with is sent however long it takes.
+func (r *Request) Commit() error {
+	r.commitLock.Lock()
+	defer r.commitLock.Unlock()
+
+	if err := r.Context.Err(); err != nil {
+		return err
+	}
+	r.committed = true
+
+	return nil
+}
+
+// abandon gives up on the request's handler unless it's committed, returning whether it did. It's only called once
+// the context is done, so the handler can't commit afterwards.
+func (r *Request) abandon() bool {
+	r.commitLock.Lock()
+	defer r.commitLock.Unlock()
+
+	return !r.committed
+}
+
+func newRequest(ctx context.Context, interaction *discordgo.Interaction, name string, command *discordgo.ApplicationCommand, options []*discordgo.ApplicationCommandInteractionDataOption) *Request {
+	req := &Request{
+		Name:        name,
+		Context:     ctx,
+		GuildID:     interaction.GuildID,
+		ChannelID:   interaction.ChannelID,
+		Member:      interaction.Member,
+		Locale:      interaction.Locale,
+		Command:     command,
+		Options:     make(Options, len(options)),
+		Interaction: interaction,
+	}
+
+	for _, option := range options {
+		req.Options[option.Name] = option
+	}
+
+	return req
+}
+
+// Options are the options a slash command was used with, keyed by name. Request.Decode reads them into a struct,
+// checking them against the command's definition.
+type Options map[string]*discordgo.ApplicationCommandInteractionDataOption
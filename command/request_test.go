@@ -0,0 +1,48 @@
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRequest(t *testing.T) {
+	interaction := &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   "guild",
+		ChannelID: "channel",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "player"}},
+		Locale:    discordgo.Japanese,
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: "command",
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "string", Type: discordgo.ApplicationCommandOptionString, Value: "value"},
+				{Name: "int", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(3)},
+				{Name: "float", Type: discordgo.ApplicationCommandOptionNumber, Value: 2.5},
+				{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: "someone"},
+			},
+		},
+	}
+
+	req := newRequest(context.Background(), interaction, "/command", nil, interaction.ApplicationCommandData().Options)
+	assert.Equal(t, "/command", req.Name)
+	assert.Equal(t, "guild", req.GuildID)
+	assert.Equal(t, "channel", req.ChannelID)
+	assert.Equal(t, "player", req.Member.User.ID)
+	assert.Equal(t, discordgo.Japanese, req.Locale)
+
+	assert.Len(t, req.Options, 4)
+	assert.Equal(t, "value", req.Options["string"].Value)
+	assert.Equal(t, "someone", req.Options["user"].Value)
+}
+
+func TestNewRequestFromComponent(t *testing.T) {
+	req := newRequest(context.Background(), &discordgo.Interaction{
+		Type: discordgo.InteractionMessageComponent,
+		Data: discordgo.MessageComponentInteractionData{CustomID: "button"},
+	}, "button", nil, nil)
+
+	assert.Empty(t, req.Options)
+}
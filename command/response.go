@@ -0,0 +1,101 @@
+package command
+
+import "github.com/bwmarrin/discordgo"
+
+// Response is what a handler sends back to the person who used its command or component.
+type Response struct {
+	Content    string
+	Embeds     []*discordgo.MessageEmbed
+	Components []discordgo.MessageComponent
+	Files      []*discordgo.File
+	// Ephemeral responses are only shown to the person who used the command.
+	Ephemeral bool
+	// AllowedMentions controls who gets pinged by mentions in the response. Nil uses Discord's default, which pings
+	// everyone mentioned.
+	AllowedMentions *discordgo.MessageAllowedMentions
+	// Modal, if set, is opened instead of sending a message. Everything else in the response is ignored. Deferred
+	// commands can't open modals.
+	Modal *Modal
+	// FollowUps are sent as separate messages after the response, in order.
+	FollowUps []*Response
+}
+
+// Modal is a pop-up form. Its submission is routed like a component, by CustomID.
+type Modal struct {
+	CustomID   string
+	Title      string
+	Components []discordgo.MessageComponent
+}
+
+// Message is a response containing just content, shown to everyone in the channel.
+func Message(content string) *Response {
+	return &Response{Content: content}
+}
+
+// Ephemeral is a response containing just content, shown only to the person who used the command.
+func Ephemeral(content string) *Response {
+	return &Response{Content: content, Ephemeral: true}
+}
+
+// NoMentions stops a response from pinging anyone it mentions.
+func NoMentions() *discordgo.MessageAllowedMentions {
+	return &discordgo.MessageAllowedMentions{}
+}
+
+// interactionResponse translates response into the first response to an interaction.
+func interactionResponse(response *Response) *discordgo.InteractionResponse {
+	if response.Modal != nil {
+		return &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID:   response.Modal.CustomID,
+				Title:      response.Modal.Title,
+				Components: response.Modal.Components,
+			},
+		}
+	}
+
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         response.Content,
+			Embeds:          response.Embeds,
+			Components:      response.Components,
+			Files:           response.Files,
+			AllowedMentions: response.AllowedMentions,
+			Flags:           response.flags(),
+		},
+	}
+}
+
+// webhookEdit translates response into an edit of a deferred interaction's response. Edits can't change whether a
+// message is ephemeral.
+func webhookEdit(response *Response) *discordgo.WebhookEdit {
+	return &discordgo.WebhookEdit{
+		Content:         &response.Content,
+		Embeds:          &response.Embeds,
+		Components:      &response.Components,
+		Files:           response.Files,
+		AllowedMentions: response.AllowedMentions,
+	}
+}
+
+// webhookParams translates response into a follow-up message.
+func webhookParams(response *Response) *discordgo.WebhookParams {
+	return &discordgo.WebhookParams{
+		Content:         response.Content,
+		Embeds:          response.Embeds,
+		Components:      response.Components,
+		Files:           response.Files,
+		AllowedMentions: response.AllowedMentions,
+		Flags:           response.flags(),
+	}
+}
+
+func (r *Response) flags() discordgo.MessageFlags {
+	if r.Ephemeral {
+		return discordgo.MessageFlagsEphemeral
+	}
+
+	return 0
+}
@@ -0,0 +1,14 @@
+package command
+
+import "github.com/bwmarrin/discordgo"
+
+// Session is the part of a discordgo.Session the Dispatcher uses to respond to interactions. Anything else that can
+// respond to them, like the console, can stand in for Discord by implementing it.
+type Session interface {
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+var _ Session = (*discordgo.Session)(nil)
@@ -0,0 +1,171 @@
+package command
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Sync makes the commands registered for appId in guildId (or globally, if guildId is empty) match commands. Discord is
+// only asked to overwrite them if they've changed, so it's safe to call on every startup. It returns whether anything
+// was overwritten. options are passed on to every request, e.g. to give them a context.
+//
+// Everything discordgo can set on a command is synced: its type, name, description, localizations, permissions, NSFW
+// flag and options. Contexts and integration types can't be set with this version of discordgo, so they're left as
+// Discord has them.
+func Sync(s *discordgo.Session, appId, guildId string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) (bool, error) {
+	if commands == nil {
+		// Discord wants an empty list to remove every command, not null.
+		commands = []*discordgo.ApplicationCommand{}
+	}
+
+	registered, err := s.ApplicationCommands(appId, guildId, options...)
+	if err != nil {
+		return false, fmt.Errorf("can't fetch registered commands: %w", err)
+	}
+
+	changed, err := commandsChanged(registered, commands)
+	if err != nil {
+		return false, err
+	} else if !changed {
+		return false, nil
+	}
+
+	if _, err := s.ApplicationCommandBulkOverwrite(appId, guildId, commands, options...); err != nil {
+		return false, fmt.Errorf("can't overwrite registered commands: %w", err)
+	}
+
+	return true, nil
+}
+
+// Unregister removes every command registered for appId in guildId (or globally, if guildId is empty).
+func Unregister(s *discordgo.Session, appId, guildId string, options ...discordgo.RequestOption) error {
+	if _, err := s.ApplicationCommandBulkOverwrite(appId, guildId, []*discordgo.ApplicationCommand{}, options...); err != nil {
+		return fmt.Errorf("can't remove registered commands: %w", err)
+	}
+
+	return nil
+}
+
+// commandsChanged compares the commands registered with Discord to the ones we want registered. Discord replaces the
+// Version of every command with its own ID for the revision it has, so the definitions themselves are compared
+// instead - bumping a command's Version is still a good habit, but it isn't what triggers a sync.
+func commandsChanged(registered, wanted []*discordgo.ApplicationCommand) (bool, error) {
+	registeredShapes, err := commandShapes(registered)
+	if err != nil {
+		return false, err
+	}
+
+	wantedShapes, err := commandShapes(wanted)
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(registeredShapes, wantedShapes), nil
+}
+
+// commandShape is the part of a command definition we set, leaving out everything Discord fills in.
+type commandShape struct {
+	Type                     discordgo.ApplicationCommandType `json:"type"`
+	Name                     string                           `json:"name"`
+	NameLocalizations        map[discordgo.Locale]string      `json:"name_localizations,omitempty"`
+	Description              string                           `json:"description,omitempty"`
+	DescriptionLocalizations map[discordgo.Locale]string      `json:"description_localizations,omitempty"`
+	DefaultMemberPermissions *int64                           `json:"default_member_permissions,omitempty"`
+	DMPermission             bool                             `json:"dm_permission"`
+	NSFW                     bool                             `json:"nsfw,omitempty"`
+	Options                  []optionShape                    `json:"options,omitempty"`
+}
+
+type optionShape struct {
+	Type                     discordgo.ApplicationCommandOptionType `json:"type"`
+	Name                     string                                 `json:"name"`
+	NameLocalizations        map[discordgo.Locale]string            `json:"name_localizations,omitempty"`
+	Description              string                                 `json:"description,omitempty"`
+	DescriptionLocalizations map[discordgo.Locale]string            `json:"description_localizations,omitempty"`
+	Required                 bool                                   `json:"required,omitempty"`
+	Autocomplete             bool                                   `json:"autocomplete,omitempty"`
+	ChannelTypes             []discordgo.ChannelType                `json:"channel_types,omitempty"`
+	MinValue                 *float64                               `json:"min_value,omitempty"`
+	MaxValue                 float64                                `json:"max_value,omitempty"`
+	MinLength                *int                                   `json:"min_length,omitempty"`
+	MaxLength                int                                    `json:"max_length,omitempty"`
+	Choices                  []choiceShape                          `json:"choices,omitempty"`
+	Options                  []optionShape                          `json:"options,omitempty"`
+}
+
+type choiceShape struct {
+	Name              string                      `json:"name"`
+	NameLocalizations map[discordgo.Locale]string `json:"name_localizations,omitempty"`
+	Value             interface{}                 `json:"value"`
+}
+
+// commandShapes encodes commands in a form that can be compared byte for byte, whatever order they're in.
+func commandShapes(commands []*discordgo.ApplicationCommand) ([]byte, error) {
+	shapes := make([]commandShape, 0, len(commands))
+	for _, cmd := range commands {
+		shape := commandShape{
+			Type:                     cmd.Type,
+			Name:                     cmd.Name,
+			Description:              cmd.Description,
+			DefaultMemberPermissions: cmd.DefaultMemberPermissions,
+			NSFW:                     cmd.NSFW != nil && *cmd.NSFW,
+			Options:                  optionShapes(cmd.Options),
+		}
+		// Commands are chat commands that can be used in DMs unless they say otherwise.
+		if shape.Type == 0 {
+			shape.Type = discordgo.ChatApplicationCommand
+		}
+		shape.DMPermission = cmd.DMPermission == nil || *cmd.DMPermission
+		if cmd.NameLocalizations != nil {
+			shape.NameLocalizations = *cmd.NameLocalizations
+		}
+		if cmd.DescriptionLocalizations != nil {
+			shape.DescriptionLocalizations = *cmd.DescriptionLocalizations
+		}
+		shapes = append(shapes, shape)
+	}
+
+	slices.SortFunc(shapes, func(a, b commandShape) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Type, b.Type))
+	})
+
+	// Numbers all come back from Discord as float64s, so encoding is what makes an int choice equal to its float64.
+	encoded, err := json.Marshal(shapes)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode commands: %w", err)
+	}
+
+	return encoded, nil
+}
+
+func optionShapes(options []*discordgo.ApplicationCommandOption) []optionShape {
+	shapes := make([]optionShape, 0, len(options))
+	for _, option := range options {
+		shape := optionShape{
+			Type:                     option.Type,
+			Name:                     option.Name,
+			NameLocalizations:        option.NameLocalizations,
+			Description:              option.Description,
+			DescriptionLocalizations: option.DescriptionLocalizations,
+			Required:                 option.Required,
+			Autocomplete:             option.Autocomplete,
+			ChannelTypes:             option.ChannelTypes,
+			MinValue:                 option.MinValue,
+			MaxValue:                 option.MaxValue,
+			MinLength:                option.MinLength,
+			MaxLength:                option.MaxLength,
+			Options:                  optionShapes(option.Options),
+		}
+		for _, choice := range option.Choices {
+			shape.Choices = append(shape.Choices, choiceShape{Name: choice.Name, NameLocalizations: choice.NameLocalizations, Value: choice.Value})
+		}
+		shapes = append(shapes, shape)
+	}
+
+	return shapes
+}
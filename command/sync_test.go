@@ -0,0 +1,142 @@
+package command
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	minSyncValue = float64(1)
+
+	syncCommands = []*discordgo.ApplicationCommand{
+		{
+			Version:     "0.1",
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        "first",
+			Description: "The first command",
+			DescriptionLocalizations: &map[discordgo.Locale]string{
+				discordgo.French: "La première commande",
+			},
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "count",
+					Description: "How many",
+					MinValue:    &minSyncValue,
+					MaxValue:    10,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "One", Value: 1},
+						{Name: "Two", Value: 2},
+					},
+				},
+			},
+		},
+		{
+			Name:        "second",
+			Description: "The second command",
+		},
+	}
+)
+
+// registered is what Discord sends back for commands once they've been registered.
+func registered(t *testing.T, commands []*discordgo.ApplicationCommand) []*discordgo.ApplicationCommand {
+	encoded, err := json.Marshal(commands)
+	require.NoError(t, err)
+
+	var decoded []*discordgo.ApplicationCommand
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	// Discord fills in the defaults of anything left unset.
+	dmPermission, nsfw := true, false
+
+	for i, cmd := range decoded {
+		cmd.ID = "id"
+		cmd.ApplicationID = "app"
+		cmd.Version = "1234567890"
+		cmd.Type = discordgo.ChatApplicationCommand
+		if cmd.DMPermission == nil {
+			cmd.DMPermission = &dmPermission
+		}
+		if cmd.NSFW == nil {
+			cmd.NSFW = &nsfw
+		}
+		cmd.Options = append([]*discordgo.ApplicationCommandOption{}, cmd.Options...)
+		decoded[i] = cmd
+	}
+
+	// Discord doesn't promise to send them back in the same order.
+	decoded[0], decoded[len(decoded)-1] = decoded[len(decoded)-1], decoded[0]
+	return decoded
+}
+
+func TestCommandsChanged(t *testing.T) {
+	t.Run("unchanged", func(t *testing.T) {
+		changed, err := commandsChanged(registered(t, syncCommands), syncCommands)
+		assert.NoError(t, err)
+		assert.False(t, changed)
+	})
+
+	t.Run("nothing registered", func(t *testing.T) {
+		changed, err := commandsChanged(nil, syncCommands)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("command removed", func(t *testing.T) {
+		changed, err := commandsChanged(registered(t, syncCommands), syncCommands[:1])
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("option changed", func(t *testing.T) {
+		remote := registered(t, syncCommands)
+		for _, cmd := range remote {
+			if cmd.Name == "first" {
+				cmd.Options[0].MaxValue = 20
+			}
+		}
+
+		changed, err := commandsChanged(remote, syncCommands)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("made NSFW", func(t *testing.T) {
+		remote := registered(t, syncCommands)
+		wanted := append([]*discordgo.ApplicationCommand{}, syncCommands...)
+		nsfw := true
+		second := *wanted[1]
+		second.NSFW = &nsfw
+		wanted[1] = &second
+
+		changed, err := commandsChanged(remote, wanted)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("localization changed", func(t *testing.T) {
+		remote := registered(t, syncCommands)
+		for _, cmd := range remote {
+			if cmd.Name == "first" {
+				cmd.DescriptionLocalizations = &map[discordgo.Locale]string{discordgo.French: "Autre chose"}
+			}
+		}
+
+		changed, err := commandsChanged(remote, syncCommands)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	})
+
+	t.Run("description changed", func(t *testing.T) {
+		remote := registered(t, syncCommands)
+		remote[0].Description = "Something else"
+
+		changed, err := commandsChanged(remote, syncCommands)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	})
+}
@@ -0,0 +1,314 @@
+// Package console runs the bot's commands from a terminal instead of Discord, so they can be tried out without a bot
+// token or a server to test in.
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// GuildID is the guild every command run from the console is used in.
+	GuildID = "console"
+
+	defaultUser    = "you"
+	defaultChannel = "console"
+	prompt         = "> "
+)
+
+const help = `Type a command the way you would in Discord, with its options as name:value:
+  /mdb answer choice:maybe... counter-offer:"1,000"
+Add as:<name> to use it as someone else, and in:<channel> to use it in another channel.
+
+Other commands:
+  click <custom-id>                      clicks the button with custom-id
+  submit <custom-id> <input-id>:<value>  submits the modal with custom-id
+  complete /mdb answer id:<typed>        suggests values for the last option
+  help                                   shows this
+  exit                                   quits`
+
+// Console turns lines typed into it into interactions, fires them through a Dispatcher, and prints the responses.
+type Console struct {
+	dispatcher *command.Dispatcher
+	commands   map[string]*discordgo.ApplicationCommand
+	session    *session
+	// lastId is the ID of the last interaction created, so every interaction gets a new one.
+	lastId int
+}
+
+// New creates a Console for the commands in modules, which should be the ones dispatcher was created with. Responses
+// are printed to out.
+func New(dispatcher *command.Dispatcher, modules []command.Module, out io.Writer) *Console {
+	c := &Console{
+		dispatcher: dispatcher,
+		commands:   map[string]*discordgo.ApplicationCommand{},
+		session:    &session{out: out},
+	}
+
+	for _, cmd := range command.ApplicationCommands(modules) {
+		c.commands[cmd.Name] = cmd
+	}
+
+	return c
+}
+
+// Run executes every line read from in until it runs out or `exit` is typed. Lines that can't be executed are
+// explained rather than stopping the console.
+func (c *Console) Run(in io.Reader) error {
+	fmt.Fprintln(c.session.out, "Type help to see what you can do.")
+
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(c.session.out, prompt); scanner.Scan(); fmt.Fprint(c.session.out, prompt) {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+
+		if err := c.Exec(line); err != nil {
+			fmt.Fprintf(c.session.out, "%v\n", err)
+		}
+	}
+
+	fmt.Fprintln(c.session.out)
+	return scanner.Err()
+}
+
+// Exec executes a single line - see help for what it can be.
+func (c *Console) Exec(line string) error {
+	tokens, err := fields(line)
+	if err != nil {
+		return err
+	} else if len(tokens) == 0 {
+		return nil
+	}
+
+	user, channel := defaultUser, defaultChannel
+	var args []string
+	for _, token := range tokens {
+		if name, ok := strings.CutPrefix(token, "as:"); ok && name != "" {
+			user = name
+		} else if name, ok := strings.CutPrefix(token, "in:"); ok && name != "" {
+			channel = name
+		} else {
+			args = append(args, token)
+		}
+	}
+	if len(args) == 0 {
+		return errors.New("nothing to do - type help to see what you can do")
+	}
+
+	var interaction *discordgo.Interaction
+	switch verb := args[0]; {
+	case verb == "help":
+		fmt.Fprintln(c.session.out, help)
+		return nil
+	case verb == "click":
+		if len(args) != 2 {
+			return errors.New("click needs the custom ID of a button, like click mdb-answer:yes:3")
+		}
+		interaction = c.interaction(discordgo.InteractionMessageComponent, user, channel, discordgo.MessageComponentInteractionData{
+			CustomID:      args[1],
+			ComponentType: discordgo.ButtonComponent,
+		})
+	case verb == "submit":
+		if len(args) < 2 {
+			return errors.New("submit needs the custom ID of a modal, followed by the values of its inputs")
+		}
+		data, err := modalData(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		interaction = c.interaction(discordgo.InteractionModalSubmit, user, channel, data)
+	case verb == "complete":
+		data, err := c.commandData(args[1:], true)
+		if err != nil {
+			return err
+		}
+		interaction = c.interaction(discordgo.InteractionApplicationCommandAutocomplete, user, channel, data)
+	case strings.HasPrefix(verb, "/"):
+		data, err := c.commandData(args, false)
+		if err != nil {
+			return err
+		}
+		interaction = c.interaction(discordgo.InteractionApplicationCommand, user, channel, data)
+	default:
+		return fmt.Errorf("%q isn't a command - type help to see what you can do", verb)
+	}
+
+	c.dispatcher.Dispatch(c.session, interaction)
+	return nil
+}
+
+// interaction creates an interaction with data, as if user had used it in channel.
+func (c *Console) interaction(interactionType discordgo.InteractionType, user, channel string, data discordgo.InteractionData) *discordgo.Interaction {
+	c.lastId++
+
+	return &discordgo.Interaction{
+		ID:        strconv.Itoa(c.lastId),
+		Type:      interactionType,
+		Data:      data,
+		GuildID:   GuildID,
+		ChannelID: channel,
+		Member: &discordgo.Member{
+			GuildID: GuildID,
+			User:    &discordgo.User{ID: user, Username: user},
+		},
+		Locale: discordgo.EnglishUS,
+	}
+}
+
+// commandData reads a slash command like `/mdb answer choice:yes` into the data Discord would send for it. Options
+// are typed according to the command's definition, and anything it doesn't define is passed as a string, for the
+// handler to reject. When focused is set, the last option is marked as the one being typed, for autocomplete.
+func (c *Console) commandData(args []string, focused bool) (discordgo.ApplicationCommandInteractionData, error) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "/") {
+		return discordgo.ApplicationCommandInteractionData{}, errors.New("that needs a slash command, like /mdb question")
+	}
+
+	name := strings.TrimPrefix(args[0], "/")
+	cmd, ok := c.commands[name]
+	if !ok {
+		return discordgo.ApplicationCommandInteractionData{}, fmt.Errorf("there's no /%s command", name)
+	}
+
+	data := discordgo.ApplicationCommandInteractionData{
+		ID:          name,
+		Name:        name,
+		CommandType: discordgo.ChatApplicationCommand,
+	}
+
+	// options is where the options being read go - the command's, or those of the last subcommand named.
+	options, definitions := &data.Options, cmd.Options
+	var last *discordgo.ApplicationCommandInteractionDataOption
+	for _, arg := range args[1:] {
+		optionName, value, isOption := strings.Cut(arg, ":")
+		definition := findOption(definitions, optionName)
+
+		if !isOption {
+			if definition == nil || (definition.Type != discordgo.ApplicationCommandOptionSubCommand && definition.Type != discordgo.ApplicationCommandOptionSubCommandGroup) {
+				return discordgo.ApplicationCommandInteractionData{}, fmt.Errorf("%q isn't a subcommand there - options look like name:value", arg)
+			}
+
+			subcommand := &discordgo.ApplicationCommandInteractionDataOption{Name: definition.Name, Type: definition.Type}
+			*options = append(*options, subcommand)
+			options, definitions = &subcommand.Options, definition.Options
+			continue
+		}
+
+		option, err := parseOption(definition, optionName, value)
+		if err != nil {
+			return discordgo.ApplicationCommandInteractionData{}, err
+		}
+		*options = append(*options, option)
+		last = option
+	}
+
+	if focused {
+		if last == nil {
+			return discordgo.ApplicationCommandInteractionData{}, errors.New("complete needs an option to suggest values for, like id:3")
+		}
+		last.Focused = true
+	}
+
+	return data, nil
+}
+
+func findOption(definitions []*discordgo.ApplicationCommandOption, name string) *discordgo.ApplicationCommandOption {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition
+		}
+	}
+
+	return nil
+}
+
+// parseOption reads the value of an option the way Discord would send it - numbers as float64s and user options as
+// IDs. Options without a definition are passed as strings.
+func parseOption(definition *discordgo.ApplicationCommandOption, name, value string) (*discordgo.ApplicationCommandInteractionDataOption, error) {
+	option := &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  name,
+		Type:  discordgo.ApplicationCommandOptionString,
+		Value: value,
+	}
+	if definition == nil {
+		return option, nil
+	}
+
+	option.Type = definition.Type
+	switch definition.Type {
+	case discordgo.ApplicationCommandOptionInteger, discordgo.ApplicationCommandOptionNumber:
+		number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s has to be a number", name)
+		}
+		option.Value = number
+	case discordgo.ApplicationCommandOptionBoolean:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s has to be true or false", name)
+		}
+		option.Value = boolean
+	case discordgo.ApplicationCommandOptionSubCommand, discordgo.ApplicationCommandOptionSubCommandGroup:
+		return nil, fmt.Errorf("%s is a subcommand, not an option", name)
+	}
+
+	return option, nil
+}
+
+// modalData reads the values typed into a modal's inputs, like `counter-offer:1000`, into the data Discord would send
+// when it's submitted.
+func modalData(customId string, inputs []string) (discordgo.ModalSubmitInteractionData, error) {
+	data := discordgo.ModalSubmitInteractionData{CustomID: customId}
+	for _, input := range inputs {
+		inputId, value, ok := strings.Cut(input, ":")
+		if !ok {
+			return discordgo.ModalSubmitInteractionData{}, fmt.Errorf("%q isn't an input - inputs look like input-id:value", input)
+		}
+
+		data.Components = append(data.Components, &discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{&discordgo.TextInput{CustomID: inputId, Value: value}},
+		})
+	}
+
+	return data, nil
+}
+
+// fields splits line on whitespace, except for those inside double quotes, which are removed.
+func fields(line string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	inToken, quoted := false, false
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted, inToken = !quoted, true
+		case unicode.IsSpace(r) && !quoted:
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+			inToken = false
+		default:
+			token.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if quoted {
+		return nil, errors.New("there's a quote that's never closed")
+	} else if inToken {
+		tokens = append(tokens, token.String())
+	}
+
+	return tokens, nil
+}
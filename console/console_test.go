@@ -0,0 +1,130 @@
+package console
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoHandler responds with who used it where, and the options it was used with.
+type echoHandler struct{}
+
+type echoOptions struct {
+	Word  string   `option:"word"`
+	Count *float64 `option:"count"`
+	Loud  bool     `option:"loud"`
+}
+
+func (echoHandler) Handle(req *command.Request) (*command.Response, error) {
+	var options echoOptions
+	if err := req.Decode(&options); err != nil {
+		return nil, err
+	}
+
+	count := "no count"
+	if options.Count != nil {
+		count = fmt.Sprint(*options.Count)
+	}
+
+	return command.Message(fmt.Sprintf("%s by %s in %s: %s, %s, %t", req.Name, req.Member.User.Mention(), req.ChannelID, options.Word, count, options.Loud)), nil
+}
+
+func (echoHandler) HandleComponent(req *command.Request, args []string) (*command.Response, error) {
+	if req.Interaction.Type == discordgo.InteractionModalSubmit {
+		return command.Ephemeral(fmt.Sprintf("%s submitted %v", req.Name, command.ModalValues(req.Interaction.ModalSubmitData()))), nil
+	}
+
+	return command.Message(fmt.Sprintf("%s clicked with %v", req.Name, args)), nil
+}
+
+func newTestConsole() (*Console, *strings.Builder) {
+	echo := command.MessageCommand{
+		CommandInfo: &discordgo.ApplicationCommand{
+			Name:        "echo",
+			Description: "echoes things",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "word", Required: true},
+				{Type: discordgo.ApplicationCommandOptionNumber, Name: "count"},
+				{Type: discordgo.ApplicationCommandOptionBoolean, Name: "loud"},
+			},
+		},
+		Handler: echoHandler{},
+		Key:     "echo",
+	}
+	modules := []command.Module{{
+		Name:       "test",
+		Commands:   []command.MessageCommand{command.NewGroup("test", "test things", echo)},
+		Components: []command.MessageComponent{{Handler: echoHandler{}, Key: "button"}},
+	}}
+
+	out := &strings.Builder{}
+	return New(command.NewDispatcher(modules, nil), modules, out), out
+}
+
+func TestExec(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`/test echo word:hi`, "/test echo by @you in console: hi, no count, false\n"},
+		{`/test echo word:"hi there" count:1,000.5 loud:true as:alice in:general`, "/test echo by @alice in general: hi there, 1000.5, true\n"},
+		{`/test echo`, "(only you can see this)\nHmm, `word` is required!\n"},
+		{`/test echo word:hi colour:red`, "(only you can see this)\nHmm, `colour` isn't an option I know about!\n"},
+		{`click button:a:b`, "button clicked with [a b]\n"},
+		{`submit button:a input:"some text"`, "(only you can see this)\nbutton submitted map[input:some text]\n"},
+		{``, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			console, out := newTestConsole()
+
+			require.NoError(t, console.Exec(test.line))
+			assert.Equal(t, test.want, out.String())
+		})
+	}
+}
+
+func TestExecRejects(t *testing.T) {
+	tests := map[string]string{
+		`/nope`:                         "there's no /nope command",
+		`/test nope`:                    `"nope" isn't a subcommand there - options look like name:value`,
+		`/test echo word:hi count:lots`: "count has to be a number",
+		`/test echo loud:maybe`:         "loud has to be true or false",
+		`/test echo word:"hi`:           "there's a quote that's never closed",
+		`complete /test echo`:           "complete needs an option to suggest values for, like id:3",
+		`submit button:a input`:         `"input" isn't an input - inputs look like input-id:value`,
+		`as:alice`:                      "nothing to do - type help to see what you can do",
+		`echo word:hi`:                  `"echo" isn't a command - type help to see what you can do`,
+	}
+
+	for line, want := range tests {
+		t.Run(line, func(t *testing.T) {
+			console, out := newTestConsole()
+
+			assert.EqualError(t, console.Exec(line), want)
+			assert.Empty(t, out.String())
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	console, out := newTestConsole()
+
+	require.NoError(t, console.Run(strings.NewReader("/test echo word:one\n/nope\nexit\n/test echo word:two\n")))
+	assert.Equal(t, "Type help to see what you can do.\n"+
+		"> /test echo by @you in console: one, no count, false\n"+
+		"> there's no /nope command\n"+
+		"> ", out.String())
+}
+
+func TestFields(t *testing.T) {
+	tokens, err := fields(`  /mdb answer  choice:"maybe..." counter-offer:"$1, 000"	as:bob ""`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/mdb", "answer", "choice:maybe...", "counter-offer:$1, 000", "as:bob", ""}, tokens)
+}
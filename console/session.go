@@ -0,0 +1,167 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// mention matches the user, role and channel mentions Discord would render as names.
+var mention = regexp.MustCompile(`<([@#])[!&]?([^<>\s]+)>`)
+
+// session stands in for Discord, printing every response instead of sending it.
+type session struct {
+	out io.Writer
+}
+
+func (s *session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	switch resp.Type {
+	case discordgo.InteractionResponseDeferredChannelMessageWithSource:
+		fmt.Fprintln(s.out, "(thinking...)")
+	case discordgo.InteractionResponseModal:
+		s.printModal(resp.Data)
+	case discordgo.InteractionApplicationCommandAutocompleteResult:
+		s.printChoices(resp.Data.Choices)
+	default:
+		s.printMessage(resp.Data.Content, resp.Data.Embeds, resp.Data.Components, resp.Data.Flags)
+	}
+
+	return nil
+}
+
+func (s *session) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	var content string
+	var embeds []*discordgo.MessageEmbed
+	var components []discordgo.MessageComponent
+	if newresp.Content != nil {
+		content = *newresp.Content
+	}
+	if newresp.Embeds != nil {
+		embeds = *newresp.Embeds
+	}
+	if newresp.Components != nil {
+		components = *newresp.Components
+	}
+
+	s.printMessage(content, embeds, components, 0)
+	return &discordgo.Message{ID: interaction.ID, ChannelID: interaction.ChannelID, Content: content}, nil
+}
+
+func (s *session) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	fmt.Fprintln(s.out, "(response deleted)")
+	return nil
+}
+
+func (s *session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.printMessage(data.Content, data.Embeds, data.Components, data.Flags)
+	return &discordgo.Message{ChannelID: interaction.ChannelID, Content: data.Content}, nil
+}
+
+// printMessage prints a message the way it'd look in Discord, as far as a terminal can show it.
+func (s *session) printMessage(content string, embeds []*discordgo.MessageEmbed, components []discordgo.MessageComponent, flags discordgo.MessageFlags) {
+	if flags&discordgo.MessageFlagsEphemeral != 0 {
+		fmt.Fprintln(s.out, "(only you can see this)")
+	}
+
+	if content != "" {
+		fmt.Fprintln(s.out, render(content))
+	}
+
+	for _, embed := range embeds {
+		for _, line := range []string{embed.Title, embed.Description} {
+			if line != "" {
+				fmt.Fprintln(s.out, indent(render(line), "| "))
+			}
+		}
+		for _, field := range embed.Fields {
+			fmt.Fprintln(s.out, indent(render(field.Name+": "+field.Value), "| "))
+		}
+		if embed.Footer != nil {
+			fmt.Fprintln(s.out, indent(render(embed.Footer.Text), "| "))
+		}
+	}
+
+	for _, component := range components {
+		s.printComponent(component)
+	}
+}
+
+func (s *session) printComponent(component discordgo.MessageComponent) {
+	switch component := component.(type) {
+	case *discordgo.ActionsRow:
+		for _, rowComponent := range component.Components {
+			s.printComponent(rowComponent)
+		}
+	case discordgo.ActionsRow:
+		s.printComponent(&component)
+	case *discordgo.Button:
+		if component.URL != "" {
+			fmt.Fprintf(s.out, "  [%s] %s\n", component.Label, component.URL)
+		} else {
+			fmt.Fprintf(s.out, "  [%s] click %s\n", component.Label, component.CustomID)
+		}
+	case discordgo.Button:
+		s.printComponent(&component)
+	case *discordgo.TextInput:
+		fmt.Fprintf(s.out, "  [%s] input %s\n", component.Label, component.CustomID)
+	case discordgo.TextInput:
+		s.printComponent(&component)
+	default:
+		fmt.Fprintf(s.out, "  (a %v component the console can't show)\n", component.Type())
+	}
+}
+
+func (s *session) printModal(data *discordgo.InteractionResponseData) {
+	fmt.Fprintf(s.out, "(a box pops up: %s)\n", data.Title)
+	for _, component := range data.Components {
+		s.printComponent(component)
+	}
+
+	usage := "submit " + data.CustomID
+	for _, inputId := range textInputs(data.Components) {
+		usage += " " + inputId + ":<value>"
+	}
+	fmt.Fprintf(s.out, "  fill it in with: %s\n", usage)
+}
+
+func (s *session) printChoices(choices []*discordgo.ApplicationCommandOptionChoice) {
+	if len(choices) == 0 {
+		fmt.Fprintln(s.out, "(no suggestions)")
+		return
+	}
+
+	for _, choice := range choices {
+		fmt.Fprintf(s.out, "  %v (%s)\n", choice.Value, choice.Name)
+	}
+}
+
+// textInputs returns the custom IDs of every text input in components.
+func textInputs(components []discordgo.MessageComponent) []string {
+	var inputIds []string
+	for _, component := range components {
+		switch component := component.(type) {
+		case *discordgo.ActionsRow:
+			inputIds = append(inputIds, textInputs(component.Components)...)
+		case discordgo.ActionsRow:
+			inputIds = append(inputIds, textInputs(component.Components)...)
+		case *discordgo.TextInput:
+			inputIds = append(inputIds, component.CustomID)
+		case discordgo.TextInput:
+			inputIds = append(inputIds, component.CustomID)
+		}
+	}
+
+	return inputIds
+}
+
+// render replaces mentions with the names they'd show up as, which in the console are just the IDs.
+func render(content string) string {
+	return mention.ReplaceAllString(content, "$1$2")
+}
+
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
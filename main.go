@@ -1,133 +1,169 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/Scraniel/go-roboto-sensei/app"
 	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/console"
 	"github.com/Scraniel/go-roboto-sensei/mdb"
-	"github.com/bwmarrin/discordgo"
 )
 
 // Bot parameters
 var (
-	GuildID = flag.String("guild", "", "Test guild ID. If not passed - bot registers commands globally")
-	mdbBot  *mdb.MillionDollarBot
+	GuildID  = flag.String("guild", "", "Test guild ID. If not passed - bot registers commands globally")
+	GuildIDs = flag.String("guilds", "", "Comma-separated guild IDs to register commands in, each optionally followed by the modules enabled in it, like 123=mdb,456. Overrides -guild")
+	Cleanup  = flag.Bool("cleanup", false, "Remove the bot's commands from Discord on shutdown")
+	HTTPAddr = flag.String("http", "", "Address to serve Discord's HTTP interactions webhook on, like :8080. If not passed - bot receives interactions over the gateway")
+
+	ConfigPath = flag.String("config", "", "YAML config file. Environment variables override it, and flags override both")
 )
 
-func init() { flag.Parse() }
+// How long in-flight interactions get to finish, and stats to save, once the bot is told to stop.
+const shutdownTimeout = 30 * time.Second
 
-var (
-	session         *discordgo.Session
-	commands        []*discordgo.ApplicationCommand
-	commandHandlers map[string]command.MessageHandler
-)
+// newConfig reads the App's config from the -config file, overridden by environment variables, which are overridden
+// in turn by any flags passed.
+func newConfig() (app.Config, error) {
+	config, err := app.LoadConfig(*ConfigPath)
+	if err != nil {
+		return app.Config{}, err
+	}
+	if err := config.LoadEnv(os.LookupEnv); err != nil {
+		return app.Config{}, err
+	}
+
+	passed := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { passed[f.Name] = true })
+
+	if passed["guilds"] || passed["guild"] {
+		list := *GuildIDs
+		if !passed["guilds"] {
+			list = *GuildID
+		}
 
-// Initializes discord library
-func init() {
-	botToken := os.Getenv("BOT_TOKEN")
-	if botToken == "" {
-		log.Fatal("BOT_TOKEN is not set!")
+		config.Guilds, err = command.ParseGuilds(list)
+		if err != nil {
+			return app.Config{}, fmt.Errorf("-guilds is invalid: %w", err)
+		}
+	}
+	if passed["cleanup"] {
+		config.Cleanup = *Cleanup
+	}
+	if passed["http"] {
+		config.HTTPAddr = *HTTPAddr
 	}
 
-	var err error
-	session, err = discordgo.New("Bot " + botToken)
-	if err != nil {
-		log.Fatalf("Invalid bot parameters: %v", err)
+	// Stats saved before they were kept per guild belong to the guild the bot was being run in.
+	if config.Storage.LegacyGuildID == "" {
+		config.Storage.LegacyGuildID = *GuildID
 	}
+
+	return config, nil
 }
 
-// initializes MDB bot
-func init() {
-	log.Println("Starting mdb...")
-	savePath := os.Getenv("SAVE_PATH")
-	if savePath == "" {
-		fmt.Println("SAVE_PATH is not set - using ./stats.json!")
-		savePath = "./stats.json"
+// runConsole runs the modules' commands from the terminal instead of Discord, until stdin is closed or `exit` is
+// typed. Every module is enabled in the console's guild, whatever -guilds says. Unless a save path is set, stats are
+// kept in a temporary file that's removed on exit.
+func runConsole(config app.Config) error {
+	if err := config.ValidateModules(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	mdbBot, err := mdb.NewMillionDollarBot(savePath)
+	// Sharing the default ./stats.json with a running bot would have each overwrite the other's stats.
+	if config.Storage.Path == "" {
+		dir, err := os.MkdirTemp("", "roboto-sensei-console")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		config.Storage.Path = filepath.Join(dir, "stats")
+		log.Printf("No save path set - saving stats to %s until the console exits!", config.Storage.Path)
+	}
+
+	log.Println("Starting mdb...")
+	store, err := app.NewStorage(config.Storage)
 	if err != nil {
-		log.Fatalf("something broke while starting the bot: %v", err)
+		return err
 	}
 
-	commands = make([]*discordgo.ApplicationCommand, 0, len(mdbBot.Commands))
-	commandHandlers = make(map[string]command.MessageHandler, len(mdbBot.Commands))
+	mdbBot := mdb.NewMillionDollarBot(store, config.MDBOptions()...)
+	modules := []command.Module{mdbBot.Module()}
 
-	for _, command := range mdbBot.Commands {
-		commands = append(commands, command.CommandInfo)
-		commandHandlers[command.Key] = command.Handler
+	dispatcher := command.NewDispatcher(modules, nil, command.Recover())
+	dispatcher.Contact = config.Admin.Contact
+	if err := console.New(dispatcher, modules, os.Stdout).Run(os.Stdin); err != nil {
+		log.Printf("Console stopped: %v", err)
 	}
 
-	// This adds the handlers themselves. When a person interacts with the bot via a command, this hook is called and
-	// the relevant handler is fired if present.
-	session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		optionMap := command.ToMap(i.ApplicationCommandData().Options)
-		log.Printf("%s command recieved from %s", i.ApplicationCommandData().Name, i.Member.Nick)
-		var messageContent string
-
-		defer func() {
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseType(discordgo.InteractionResponseChannelMessageWithSource),
-				Data: &discordgo.InteractionResponseData{
-					Content: messageContent,
-				},
-			})
-		}()
+	log.Println("Saving stats...")
+	if err := mdbBot.Close(); err != nil {
+		log.Printf("Couldn't save stats on shutdown: %v", err)
+	}
 
-		if i.Member == nil {
-			messageContent = "Sorry, you can't use this bot in DMs."
-			return
-		}
+	return nil
+}
 
-		if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-			messageContent = h.Handle(i.Member, optionMap)
-		}
-	})
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [console]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Runs the bot. With console, commands are typed into the terminal instead of coming from Discord. The console keeps")
+	fmt.Fprintln(out, "stats in a temporary file that's removed when it exits, unless SAVE_PATH or storage.path in the config is set.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Flags:")
+	flag.PrintDefaults()
 }
 
 func main() {
-	session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
-		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
-	})
+	flag.Usage = usage
+	flag.Parse()
 
-	err := session.Open()
+	config, err := newConfig()
 	if err != nil {
-		log.Fatalf("Cannot open the session: %v", err)
+		log.Fatalf("Cannot load the config: %v", err)
 	}
 
-	// This part registers the commands in Discord so they pop up when you type '/'.
-	log.Println("Adding commands...")
-	registeredCommands := make([]*discordgo.ApplicationCommand, len(commands))
-	for i, v := range commands {
-		cmd, err := session.ApplicationCommandCreate(session.State.User.ID, *GuildID, v)
-		if err != nil {
-			log.Panicf("Cannot create '%v' command: %v", v.Name, err)
+	if flag.Arg(0) == "console" {
+		if err := runConsole(config); err != nil {
+			log.Fatalf("Cannot start the console: %v", err)
 		}
-		registeredCommands[i] = cmd
-		log.Printf("Command %s added.", v.Name)
+		return
 	}
 
-	defer session.Close()
-
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	log.Println("Press Ctrl+C to exit")
-	<-stop
+	bot, err := app.New(config)
+	if err != nil {
+		log.Fatalf("Cannot start the bot: %v", err)
+	}
 
-	log.Println("Removing commands...")
+	// Stop on Ctrl+C, including while still starting up.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	for _, v := range registeredCommands {
-		err := session.ApplicationCommandDelete(session.State.User.ID, *GuildID, v.ID)
-		if err != nil {
-			log.Panicf("Cannot delete '%v' command: %v", v.Name, err)
-		}
+	startErr := bot.Start(ctx)
+	if startErr != nil {
+		log.Printf("Cannot start the bot: %v", startErr)
+	} else {
+		log.Println("Press Ctrl+C to exit")
+		<-ctx.Done()
+	}
 
-		log.Printf("Command %s removed.", v.Name)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := bot.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Couldn't shut down cleanly: %v", err)
 	}
 
+	if startErr != nil {
+		os.Exit(1)
+	}
 	log.Println("Gracefully shutting down.")
 }
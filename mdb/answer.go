@@ -2,17 +2,17 @@ package mdb
 
 import (
 	"fmt"
-	"log"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
+	"github.com/Scraniel/go-roboto-sensei/command"
 	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
 	"github.com/bwmarrin/discordgo"
 )
 
 const (
-	answerCommandVersion = "0.1"
+	answerCommandVersion = "0.2"
 	answerCommandId      = "answer"
 
 	counterOfferOptionId = "counter-offer"
@@ -25,12 +25,14 @@ const (
 	questionIdOptionId = "id"
 )
 
-var (
-	// Unfortunately must be variables instead of constants so that they're addressable.
-	minCounterOfferDollars = float64(1)
-	maxCounterOfferDollars = float64(5000000)
+// counterOfferBounds are the smallest and largest counter-offers people can make, in dollars.
+type counterOfferBounds struct {
+	min, max float64
+}
 
-	answerCommandInfo = &discordgo.ApplicationCommand{
+// answerCommandInfo describes `/answer`, with its `counter-offer` option limited to counterOffers.
+func answerCommandInfo(counterOffers counterOfferBounds) *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
 		Version:     answerCommandVersion,
 		Type:        discordgo.ChatApplicationCommand,
 		Name:        answerCommandId,
@@ -59,81 +61,110 @@ var (
 			{
 				Type:        discordgo.ApplicationCommandOptionNumber,
 				Name:        counterOfferOptionId,
-				Description: "Used with `maybe...`, include a `counter-offer`. Must be between `0` and `5000000`.",
-				MinValue:    &minCounterOfferDollars,
-				MaxValue:    maxCounterOfferDollars,
+				Description: fmt.Sprintf("Used with `maybe...`, include a `counter-offer`. Must be between `%.0f` and `%.0f`.", counterOffers.min, counterOffers.max),
+				MinValue:    &counterOffers.min,
+				MaxValue:    counterOffers.max,
 				Required:    false,
 			},
 			{
-				Type:        discordgo.ApplicationCommandOptionString,
-				Name:        questionIdOptionId,
-				Description: "Optional: ID of a previously asked question. Defaults to most recently asked question.",
-				Required:    false,
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         questionIdOptionId,
+				Description:  "Optional: ID of a previously asked question. Defaults to the last question asked in this channel.",
+				Required:     false,
+				Autocomplete: true,
 			},
 		},
 	}
-)
+}
+
+// answerOptions are the options of `/answer`.
+type answerOptions struct {
+	Choice       string   `option:"choice"`
+	CounterOffer *float64 `option:"counter-offer"`
+	QuestionId   string   `option:"id"`
+}
 
 type AnswerHandler struct {
 	storage storage.Storage
 }
 
-func (h *AnswerHandler) Handle(caller *discordgo.Member, options map[string]interface{}) string {
-	var questionId string
-	if val, ok := options[questionIdOptionId]; !ok {
-		// questionId is already empty string
-	} else {
-		questionId = val.(string)
+func (h *AnswerHandler) Handle(req *command.Request) (*command.Response, error) {
+	var options answerOptions
+	if err := req.Decode(&options); err != nil {
+		return nil, err
 	}
 
 	var offer uint
-	choice := options[choiceOptionId].(string)
-	switch choice {
+	switch options.Choice {
 	case yesChoiceKey:
 		offer = OneMillion
 	case noChoiceKey:
 		offer = 0
 	case maybeChoiceKey:
-		if val, ok := options[counterOfferOptionId]; !ok || val == nil {
-			return "Make sure to include your `counter-offer` if you're answering `maybe...`!"
-		} else {
-			offer = uint(val.(float64))
+		if options.CounterOffer == nil {
+			return command.Ephemeral("Make sure to include your `counter-offer` if you're answering `maybe...`!"), nil
 		}
+		offer = uint(*options.CounterOffer)
 	default:
-		log.Printf("we don't know how to handle the answer: %v.", choice)
-		return "Something fucky's going on if you're getting this response. Please tell Danny."
+		return nil, fmt.Errorf("we don't know how to handle the answer: %v", options.Choice)
 	}
 
+	questionId := options.QuestionId
 	if questionId == "" {
-		mostRecentQuestion, err := h.storage.GetMostRecentQuestionId()
+		currentQuestion, err := h.storage.GetCurrentQuestionId(req.GuildID, req.ChannelID)
 		if err == storage.ErrNoQuestionsAsked {
-			return fmt.Sprintf("No one has asked for any questions yet (or my memory has been reset)! Try `/%s`", questionCommandId)
+			return command.Ephemeral(fmt.Sprintf("No one has asked for any questions in this channel yet! Try `%s`, or answer an older question with its `%s`.", slashCommand(questionCommandId), questionIdOptionId)), nil
 		} else if err != nil {
-			log.Printf("GetMostRecentQuestionId returned an error: %v.", err)
-			return "You shouldn't be able to get this message. Good job. Plase tell Danny."
+			return nil, fmt.Errorf("GetCurrentQuestionId returned an error: %w", err)
 		}
 
-		questionId = mostRecentQuestion
-	} else if !h.storage.HasQuestionBeenAsked(questionId) {
-		return fmt.Sprintf("No question with that ID has been asked! Try `/%s` for a new qustion.", questionCommandId)
+		questionId = currentQuestion
 	}
 
-	stats := h.storage.UpdateStats(questionId, caller.User.ID, offer)
-	return getResponse(questionId, caller.User, offer, stats)
+	return recordAnswer(h.storage, req, questionId, offer)
+}
+
+// Autocomplete suggests questions recently asked in the channel for the `id` option.
+func (h *AnswerHandler) Autocomplete(req *command.Request, focused *discordgo.ApplicationCommandInteractionDataOption) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	return suggestQuestions(h.storage, req, focused)
+}
+
+// recordAnswer stores the caller's offer to questionId and returns the response to send them. Every way of answering
+// goes through here.
+func recordAnswer(store storage.Storage, req *command.Request, questionId string, offer uint) (*command.Response, error) {
+	if asked, err := store.HasQuestionBeenAsked(req.GuildID, questionId); err != nil {
+		return nil, fmt.Errorf("HasQuestionBeenAsked returned an error: %w", err)
+	} else if !asked {
+		return command.Ephemeral(fmt.Sprintf("No question with that ID has been asked! Try `%s` for a new qustion.", slashCommand(questionCommandId))), nil
+	}
+
+	if err := req.Commit(); err != nil {
+		return nil, err
+	}
+
+	caller := req.Member.User
+	stats, err := store.UpdateStats(req.GuildID, questionId, caller.ID, offer)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateStats returned an error: %w", err)
+	}
+
+	return command.Message(getResponse(questionId, caller, offer, stats)), nil
 }
 
 func getResponse(questionId string, asker *discordgo.User, offer uint, stats storage.PlayerStats) string {
+	millions := float64(stats.GetTotalMoney()) / float64(OneMillion)
+	return fmt.Sprintf("Thanks %s, for question ID `%s` you answered `%s`! You've currently got $%.2f million! To see your full stats, try `%s`", asker.Mention(), questionId, describeOffer(offer), millions, slashCommand(statsCommandId))
+}
+
+// describeOffer turns an offer back into the answer the player gave.
+func describeOffer(offer uint) string {
 	printer := message.NewPrinter(language.English)
 
-	var answer string
 	if offer == 0 {
-		answer = "no"
+		return "no"
 	} else if offer == OneMillion {
-		answer = "yes"
+		return "yes"
 	} else {
-		answer = printer.Sprintf("yes... but only if you give me $%d!", offer)
+		return printer.Sprintf("yes... but only if you give me $%d!", offer)
 	}
-
-	millions := float64(stats.GetTotalMoney()) / float64(OneMillion)
-	return fmt.Sprintf("Thanks %s, for question ID `%s` you answered `%s`! You've currently got $%.2f million! To see your full stats, try `/stats`", asker.Mention(), questionId, answer, millions)
 }
@@ -0,0 +1,130 @@
+package mdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// answerButtonKey is the custom ID key of the buttons under a question. Their args are the choice and question ID.
+	answerButtonKey = "mdb-answer"
+	// counterOfferModalKey is the custom ID key of the modal asking for a counter-offer. Its arg is the question ID.
+	counterOfferModalKey = "mdb-counter-offer"
+
+	counterOfferInputId = "counter-offer"
+)
+
+// answerButtons lets players answer questionId without typing out `/answer`.
+func answerButtons(questionId string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Yes",
+					Style:    discordgo.SuccessButton,
+					CustomID: command.CustomId(answerButtonKey, yesChoiceKey, questionId),
+				},
+				discordgo.Button{
+					Label:    "No",
+					Style:    discordgo.DangerButton,
+					CustomID: command.CustomId(answerButtonKey, noChoiceKey, questionId),
+				},
+				discordgo.Button{
+					Label:    "Maybe...",
+					Style:    discordgo.SecondaryButton,
+					CustomID: command.CustomId(answerButtonKey, maybeChoiceKey, questionId),
+				},
+			},
+		},
+	}
+}
+
+// AnswerButtonHandler records answers from the buttons under a question. `maybe...` opens a modal asking for the
+// counter-offer instead.
+type AnswerButtonHandler struct {
+	storage       storage.Storage
+	counterOffers counterOfferBounds
+}
+
+func (h *AnswerButtonHandler) HandleComponent(req *command.Request, args []string) (*command.Response, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("answer button has unexpected args: %v", args)
+	}
+
+	choice, questionId := args[0], args[1]
+	switch choice {
+	case yesChoiceKey:
+		return recordAnswer(h.storage, req, questionId, OneMillion)
+	case noChoiceKey:
+		return recordAnswer(h.storage, req, questionId, 0)
+	case maybeChoiceKey:
+		return counterOfferModal(questionId, h.counterOffers), nil
+	default:
+		return nil, fmt.Errorf("we don't know how to handle the answer: %v", choice)
+	}
+}
+
+func counterOfferModal(questionId string, counterOffers counterOfferBounds) *command.Response {
+	return &command.Response{
+		Modal: &command.Modal{
+			CustomID: command.CustomId(counterOfferModalKey, questionId),
+			Title:    fmt.Sprintf("Maybe... (question %s)", questionId),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    counterOfferInputId,
+							Label:       "I'd do it for this much:",
+							Style:       discordgo.TextInputShort,
+							Placeholder: fmt.Sprintf("A dollar amount between %.0f and %.0f", counterOffers.min, counterOffers.max),
+							Required:    true,
+							MaxLength:   20,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// CounterOfferModalHandler records the counter-offer submitted through the modal opened by the `maybe...` button.
+type CounterOfferModalHandler struct {
+	storage       storage.Storage
+	counterOffers counterOfferBounds
+}
+
+func (h *CounterOfferModalHandler) HandleComponent(req *command.Request, args []string) (*command.Response, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("counter-offer modal has unexpected args: %v", args)
+	}
+
+	value := command.ModalValues(req.Interaction.ModalSubmitData())[counterOfferInputId]
+	offer, err := parseCounterOffer(value, h.counterOffers)
+	if err != nil {
+		return command.Ephemeral(fmt.Sprintf("`%s` isn't a counter-offer I understand! It has to be a dollar amount between %.0f and %.0f.", value, h.counterOffers.min, h.counterOffers.max)), nil
+	}
+
+	return recordAnswer(h.storage, req, args[0], offer)
+}
+
+// parseCounterOffer reads a dollar amount typed in by a player, like `$250,000`, making sure it's within bounds - the
+// same ones as the `counter-offer` option on `/answer`.
+func parseCounterOffer(value string, bounds counterOfferBounds) (uint, error) {
+	cleaned := strings.NewReplacer("$", "", ",", "", " ", "").Replace(value)
+	dollars, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	// Written this way round so NaN, which ParseFloat accepts, isn't in range either.
+	if !(dollars >= bounds.min && dollars <= bounds.max) {
+		return 0, fmt.Errorf("%v is out of range", dollars)
+	}
+
+	return uint(dollars), nil
+}
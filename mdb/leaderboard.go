@@ -0,0 +1,129 @@
+package mdb
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	leaderboardCommandVersion = "0.1"
+	leaderboardCommandId      = "leaderboard"
+
+	metricOptionId = "metric"
+
+	leaderboardPageSize = 10
+)
+
+var (
+	leaderboardCommandInfo = &discordgo.ApplicationCommand{
+		Version:     leaderboardCommandVersion,
+		Type:        discordgo.ChatApplicationCommand,
+		Name:        leaderboardCommandId,
+		Description: "Who's raking in the most cash? See how everyone in the server compares!",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        metricOptionId,
+				Description: "Optional: what to rank everyone by. Defaults to total money.",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{
+						Name:  "Total money",
+						Value: string(storage.MetricTotalMoney),
+					},
+					{
+						Name:  "Most `no` answers",
+						Value: string(storage.MetricNoAnswers),
+					},
+					{
+						Name:  "Highest counter-offer",
+						Value: string(storage.MetricHighestCounterOffer),
+					},
+					{
+						Name:  "Most questions answered",
+						Value: string(storage.MetricQuestionsAnswered),
+					},
+				},
+				Required: false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        pageOptionId,
+				Description: "Optional: which page of the leaderboard to show. Defaults to the first.",
+				MinValue:    &minPage,
+				Required:    false,
+			},
+		},
+	}
+
+	metricTitles = map[storage.LeaderboardMetric]string{
+		storage.MetricTotalMoney:          "Richest players",
+		storage.MetricNoAnswers:           "Most `no` answers",
+		storage.MetricHighestCounterOffer: "Highest counter-offers",
+		storage.MetricQuestionsAnswered:   "Most questions answered",
+	}
+)
+
+// leaderboardOptions are the options of `/leaderboard`.
+type leaderboardOptions struct {
+	Metric storage.LeaderboardMetric `option:"metric"`
+	Page   int                       `option:"page"`
+}
+
+type LeaderboardHandler struct {
+	storage storage.Storage
+}
+
+func (h *LeaderboardHandler) Handle(req *command.Request) (*command.Response, error) {
+	options := leaderboardOptions{Metric: storage.MetricTotalMoney, Page: 1}
+	if err := req.Decode(&options); err != nil {
+		return nil, err
+	}
+
+	metric, page := options.Metric, options.Page
+
+	entries, total, err := h.storage.GetLeaderboard(req.GuildID, metric, (page-1)*leaderboardPageSize, leaderboardPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("GetLeaderboard returned an error: %w", err)
+	}
+
+	if total == 0 {
+		return command.Message(fmt.Sprintf("No one's on this leaderboard yet! Try `%s` and `%s` to get started.", slashCommand(questionCommandId), slashCommand(answerCommandId))), nil
+	}
+
+	pages := (total + leaderboardPageSize - 1) / leaderboardPageSize
+	if len(entries) == 0 {
+		return command.Ephemeral(fmt.Sprintf("The leaderboard only has %d page(s)!", pages)), nil
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**%s (page %d of %d)**\n", metricTitles[metric], page, pages))
+	for _, entry := range entries {
+		player := &discordgo.User{ID: entry.PlayerId}
+		response.WriteString(fmt.Sprintf("%d. %s: %s\n", entry.Rank, player.Mention(), describeScore(metric, entry.Score)))
+	}
+
+	return &command.Response{Content: response.String(), AllowedMentions: command.NoMentions()}, nil
+}
+
+// describeScore formats a score the way it makes sense for metric.
+func describeScore(metric storage.LeaderboardMetric, score uint) string {
+	printer := message.NewPrinter(language.English)
+
+	switch metric {
+	case storage.MetricTotalMoney:
+		return printer.Sprintf("$%.2f million", float64(score)/float64(OneMillion))
+	case storage.MetricNoAnswers:
+		return printer.Sprintf("%d `no` answer(s)", score)
+	case storage.MetricHighestCounterOffer:
+		return printer.Sprintf("$%d", score)
+	default:
+		return printer.Sprintf("%d question(s)", score)
+	}
+}
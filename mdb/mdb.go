@@ -1,44 +1,157 @@
 package mdb
 
 import (
-	_ "embed"
-	"fmt"
-
 	"github.com/Scraniel/go-roboto-sensei/command"
 	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
 )
 
 const (
-	OneMillion = uint(1000000)
+	OneMillion = storage.OneMillion
+
+	// mdbCommandId is the command every MDB command is a subcommand of, like `/mdb question`.
+	mdbCommandId = "mdb"
+
+	// DefaultMinCounterOffer and DefaultMaxCounterOffer bound counter-offers, in dollars, unless WithCounterOfferBounds
+	// says otherwise.
+	DefaultMinCounterOffer = float64(1)
+	DefaultMaxCounterOffer = float64(5000000)
 )
 
+// Option configures optional behaviour of the bot.
+type Option func(*options)
+
+type options struct {
+	counterOffers counterOfferBounds
+	contact       string
+	buttons       bool
+	leaderboard   bool
+	results       bool
+}
+
+// WithCounterOfferBounds sets the smallest and largest counter-offers people can make, in dollars.
+func WithCounterOfferBounds(min, max float64) Option {
+	return func(o *options) {
+		o.counterOffers = counterOfferBounds{min, max}
+	}
+}
+
+// WithContact sets who people are told to get in touch with when the bot needs a hand, like when it's run out of
+// questions.
+func WithContact(contact string) Option {
+	return func(o *options) {
+		o.contact = contact
+	}
+}
+
+// WithoutButtons stops questions from coming with buttons to answer them, so answers have to be typed out.
+func WithoutButtons() Option {
+	return func(o *options) {
+		o.buttons = false
+	}
+}
+
+// WithoutLeaderboard leaves out `/mdb leaderboard`.
+func WithoutLeaderboard() Option {
+	return func(o *options) {
+		o.leaderboard = false
+	}
+}
+
+// WithoutResults leaves out `/mdb results`.
+func WithoutResults() Option {
+	return func(o *options) {
+		o.results = false
+	}
+}
+
 type MillionDollarBot struct {
-	storage  storage.Storage
-	Commands []command.MessageCommand
+	storage    storage.Storage
+	Commands   []command.MessageCommand
+	Components []command.MessageComponent
 }
 
-func NewMillionDollarBot(savePath string) (*MillionDollarBot, error) {
-	storage, err := storage.NewLocalStorage(savePath)
-	if err != nil {
-		return nil, fmt.Errorf("can't create local storage: %w", err)
+// NewMillionDollarBot creates the bot on top of storage. The bot takes ownership of storage and closes it in Close.
+func NewMillionDollarBot(storage storage.Storage, opts ...Option) *MillionDollarBot {
+	o := options{
+		counterOffers: counterOfferBounds{DefaultMinCounterOffer, DefaultMaxCounterOffer},
+		contact:       command.DefaultContact,
+		buttons:       true,
+		leaderboard:   true,
+		results:       true,
+	}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
 	bot := &MillionDollarBot{
 		storage: storage,
 	}
 
-	bot.Commands = []command.MessageCommand{
+	subcommands := []command.MessageCommand{
 		{
-			CommandInfo: answerCommandInfo,
+			CommandInfo: answerCommandInfo(o.counterOffers),
 			Handler:     &AnswerHandler{storage},
 			Key:         answerCommandId,
 		},
 		{
 			CommandInfo: questionCommandInfo,
-			Handler:     &QuestionHandler{storage},
+			Handler:     &QuestionHandler{storage: storage, contact: o.contact, buttons: o.buttons},
 			Key:         questionCommandId,
 		},
+		{
+			CommandInfo: statsCommandInfo,
+			Handler:     &StatsHandler{storage},
+			Key:         statsCommandId,
+			Deferred:    true,
+		},
+	}
+	if o.leaderboard {
+		subcommands = append(subcommands, command.MessageCommand{
+			CommandInfo: leaderboardCommandInfo,
+			Handler:     &LeaderboardHandler{storage},
+			Key:         leaderboardCommandId,
+			Deferred:    true,
+		})
 	}
+	if o.results {
+		subcommands = append(subcommands, command.MessageCommand{
+			CommandInfo: resultsCommandInfo,
+			Handler:     &ResultsHandler{storage},
+			Key:         resultsCommandId,
+		})
+	}
+	bot.Commands = []command.MessageCommand{command.NewGroup(mdbCommandId, "You get a million dollars, but...", subcommands...)}
+
+	// Buttons on questions asked before they were turned off keep working.
+	bot.Components = []command.MessageComponent{
+		{
+			Handler: &AnswerButtonHandler{storage: storage, counterOffers: o.counterOffers},
+			Key:     answerButtonKey,
+		},
+		{
+			Handler: &CounterOfferModalHandler{storage: storage, counterOffers: o.counterOffers},
+			Key:     counterOfferModalKey,
+		},
+	}
+
+	return bot
+}
+
+// Module bundles up everything the bot handles, so it can be enabled per guild.
+func (b *MillionDollarBot) Module() command.Module {
+	return command.Module{
+		Name:       mdbCommandId,
+		Commands:   b.Commands,
+		Components: b.Components,
+	}
+}
+
+// slashCommand is how people type the MDB command with id, for pointing them at it.
+func slashCommand(id string) string {
+	return "/" + mdbCommandId + " " + id
+}
 
-	return bot, nil
+// Close flushes everything the bot has recorded to storage. It should be called before the process exits.
+func (b *MillionDollarBot) Close() error {
+	return b.storage.Close()
 }
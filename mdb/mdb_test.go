@@ -0,0 +1,329 @@
+package mdb_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/command/commandtest"
+	"github.com/Scraniel/go-roboto-sensei/mdb"
+	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBot(t *testing.T, opts ...mdb.Option) (*commandtest.Harness, storage.Storage) {
+	store, err := storage.NewLocalStorage(t.TempDir()+"/stats.json", storage.WithAutosaveInterval(0))
+	require.NoError(t, err)
+
+	bot := mdb.NewMillionDollarBot(store, opts...)
+	t.Cleanup(func() { bot.Close() })
+
+	return commandtest.New([]command.Module{bot.Module()}, command.Recover()), store
+}
+
+func mdbCommand(subcommand string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return commandtest.Command("mdb", commandtest.Subcommand(subcommand, options...))
+}
+
+// askQuestion asks a question in the default channel, returning its ID.
+func askQuestion(t *testing.T, h *commandtest.Harness, store storage.Storage) string {
+	messages := h.Run("asker", mdbCommand("question"))
+	require.Len(t, messages, 1)
+
+	questionId, err := store.GetCurrentQuestionId(commandtest.GuildID, commandtest.ChannelID)
+	require.NoError(t, err)
+
+	return questionId
+}
+
+func TestQuestion(t *testing.T) {
+	h, store := newBot(t)
+
+	messages := h.Run("asker", mdbCommand("question"))
+	require.Len(t, messages, 1)
+	assert.False(t, messages[0].Ephemeral)
+	assert.Contains(t, messages[0].Content, "You get a million dollars, but...")
+
+	questionId, err := store.GetCurrentQuestionId(commandtest.GuildID, commandtest.ChannelID)
+	require.NoError(t, err)
+	assert.Contains(t, messages[0].Content, "(ID: `"+questionId+"`)")
+
+	require.Len(t, messages[0].Components, 1)
+	row := messages[0].Components[0].(discordgo.ActionsRow)
+	var customIds []string
+	for _, button := range row.Components {
+		customIds = append(customIds, button.(discordgo.Button).CustomID)
+	}
+	assert.Equal(t, []string{
+		command.CustomId("mdb-answer", "yes", questionId),
+		command.CustomId("mdb-answer", "no", questionId),
+		command.CustomId("mdb-answer", "maybe...", questionId),
+	}, customIds)
+}
+
+func TestAnswer(t *testing.T) {
+	h, store := newBot(t)
+	questionId := askQuestion(t, h, store)
+
+	messages := h.Run("alice", mdbCommand("answer", commandtest.String("choice", "yes")))
+	require.Len(t, messages, 1)
+	assert.False(t, messages[0].Ephemeral)
+	assert.Contains(t, messages[0].Content, "you answered `yes`")
+
+	messages = h.Run("bob", mdbCommand("answer",
+		commandtest.String("choice", "maybe..."),
+		commandtest.Number("counter-offer", 2500),
+		commandtest.String("id", questionId),
+	))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "$2,500")
+
+	stats, err := store.GetStats(commandtest.GuildID, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint{questionId: mdb.OneMillion}, stats.Answered)
+
+	stats, err = store.GetStats(commandtest.GuildID, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint{questionId: 2500}, stats.Answered)
+}
+
+func TestAnswerRejected(t *testing.T) {
+	h, store := newBot(t)
+
+	messages := h.Run("alice", mdbCommand("answer", commandtest.String("choice", "yes")))
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].Ephemeral)
+	assert.Contains(t, messages[0].Content, "No one has asked for any questions in this channel yet!")
+
+	askQuestion(t, h, store)
+
+	tests := map[string][]*discordgo.ApplicationCommandInteractionDataOption{
+		"Make sure to include your `counter-offer`":  {commandtest.String("choice", "maybe...")},
+		"No question with that ID has been asked!":   {commandtest.String("choice", "no"), commandtest.String("id", "nope")},
+		"`choice` has to be one of the choices":      {commandtest.String("choice", "sure")},
+		"`counter-offer` can't be more than 5000000": {commandtest.String("choice", "maybe..."), commandtest.Number("counter-offer", 5000001)},
+	}
+	for want, options := range tests {
+		t.Run(want, func(t *testing.T) {
+			messages := h.Run("alice", mdbCommand("answer", options...))
+			require.Len(t, messages, 1)
+			assert.True(t, messages[0].Ephemeral)
+			assert.Contains(t, messages[0].Content, want)
+		})
+	}
+
+	stats, err := store.GetStats(commandtest.GuildID, "alice")
+	require.NoError(t, err)
+	assert.Empty(t, stats.Answered)
+}
+
+func TestAnswerButtons(t *testing.T) {
+	h, store := newBot(t)
+	questionId := askQuestion(t, h, store)
+
+	messages := h.Run("alice", commandtest.Click(command.CustomId("mdb-answer", "no", questionId)))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "you answered `no`")
+
+	messages = h.Run("bob", commandtest.Click(command.CustomId("mdb-answer", "maybe...", questionId)))
+	assert.Empty(t, messages)
+	calls := h.Session.Calls()
+	modal := calls[len(calls)-1].Response
+	require.Equal(t, discordgo.InteractionResponseModal, modal.Type)
+	assert.Equal(t, command.CustomId("mdb-counter-offer", questionId), modal.Data.CustomID)
+
+	messages = h.Run("bob", commandtest.Submit(modal.Data.CustomID, map[string]string{"counter-offer": "$1,000,000,000"}))
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].Ephemeral)
+
+	messages = h.Run("bob", commandtest.Submit(modal.Data.CustomID, map[string]string{"counter-offer": "$250,000"}))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "$250,000")
+
+	stats, err := store.GetStats(commandtest.GuildID, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint{questionId: 0}, stats.Answered)
+
+	stats, err = store.GetStats(commandtest.GuildID, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint{questionId: 250000}, stats.Answered)
+}
+
+func TestStats(t *testing.T) {
+	h, store := newBot(t)
+
+	messages := h.Run("alice", mdbCommand("stats"))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "hasn't answered any questions yet!")
+
+	questionId := askQuestion(t, h, store)
+	h.Run("alice", mdbCommand("answer", commandtest.String("choice", "yes")))
+
+	messages = h.Run("bob", mdbCommand("stats", commandtest.User("user", "alice")))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "**Stats for <@alice>**")
+	assert.Contains(t, messages[0].Content, "`"+questionId+"`")
+}
+
+func TestStatsWhileAnswering(t *testing.T) {
+	h, store := newBot(t)
+	askQuestion(t, h, store)
+	h.Run("alice", mdbCommand("answer", commandtest.String("choice", "yes")))
+
+	// Reading alice's stats while she answers more questions mustn't race with storage updating them.
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			h.Run("bob", mdbCommand("stats", commandtest.User("user", "alice")))
+		}()
+		go func() {
+			defer wg.Done()
+			questionId, err := store.GetUnaskedQuestion(commandtest.GuildID, "asker", commandtest.ChannelID)
+			if assert.NoError(t, err) {
+				h.Run("alice", mdbCommand("answer", commandtest.String("choice", "no"), commandtest.String("id", questionId.Id)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats, err := store.GetStats(commandtest.GuildID, "alice")
+	require.NoError(t, err)
+	assert.Len(t, stats.Answered, 21)
+}
+
+func TestLeaderboard(t *testing.T) {
+	h, store := newBot(t)
+
+	messages := h.Run("alice", mdbCommand("leaderboard"))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "No one's on this leaderboard yet!")
+
+	askQuestion(t, h, store)
+	h.Run("alice", mdbCommand("answer", commandtest.String("choice", "yes")))
+	h.Run("bob", mdbCommand("answer", commandtest.String("choice", "no")))
+	h.Run("carol", mdbCommand("answer", commandtest.String("choice", "maybe..."), commandtest.Number("counter-offer", 2500)))
+
+	messages = h.Run("alice", mdbCommand("leaderboard"))
+	require.Len(t, messages, 1)
+	assert.False(t, messages[0].Ephemeral)
+	assert.Contains(t, messages[0].Content, "**Richest players (page 1 of 1)**\n1. <@alice>: $1.00 million\n2. <@carol>: $0.00 million\n")
+
+	messages = h.Run("alice", mdbCommand("leaderboard", commandtest.String("metric", string(storage.MetricHighestCounterOffer))))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "**Highest counter-offers (page 1 of 1)**\n1. <@carol>: $2,500\n")
+
+	messages = h.Run("alice", mdbCommand("leaderboard", commandtest.Int("page", 2)))
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].Ephemeral)
+	assert.Equal(t, "The leaderboard only has 1 page(s)!", messages[0].Content)
+}
+
+func TestResults(t *testing.T) {
+	h, store := newBot(t)
+
+	messages := h.Run("alice", mdbCommand("results", commandtest.String("id", "nope")))
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].Ephemeral)
+	assert.Contains(t, messages[0].Content, "No question with that ID has been asked!")
+
+	questionId := askQuestion(t, h, store)
+
+	results := func() string {
+		messages := h.Run("alice", mdbCommand("results", commandtest.String("id", questionId)))
+		require.Len(t, messages, 1)
+		assert.False(t, messages[0].Ephemeral)
+		assert.Contains(t, messages[0].Content, "**Results for question `"+questionId+"`**")
+		return messages[0].Content
+	}
+	counterOffer := func(player string, dollars float64) {
+		h.Run(player, mdbCommand("answer", commandtest.String("choice", "maybe..."), commandtest.Number("counter-offer", dollars)))
+	}
+
+	assert.Contains(t, results(), "No one has answered yet!")
+
+	h.Run("alice", mdbCommand("answer", commandtest.String("choice", "yes")))
+	h.Run("bob", mdbCommand("answer", commandtest.String("choice", "no")))
+	counterOffer("carol", 100)
+	counterOffer("dave", 300000)
+	counterOffer("erin", 200)
+
+	content := results()
+	assert.Contains(t, content, "5 answer(s): 1 `yes`, 1 `no`, 3 `maybe...`\n")
+	assert.Contains(t, content, "Median counter-offer: $200\n")
+	assert.Contains(t, content, "Counter-offers ranged from $100 (<@carol>) to $300,000 (<@dave>)\n")
+
+	// With an even number of counter-offers, the median is halfway between the middle two.
+	counterOffer("frank", 400)
+
+	content = results()
+	assert.Contains(t, content, "6 answer(s): 1 `yes`, 1 `no`, 4 `maybe...`\n")
+	assert.Contains(t, content, "Median counter-offer: $300\n")
+	assert.Contains(t, content, "Counter-offers ranged from $100 (<@carol>) to $300,000 (<@dave>)\n")
+}
+
+func TestResultsAutocomplete(t *testing.T) {
+	h, store := newBot(t)
+	questionId := askQuestion(t, h, store)
+
+	h.Run("alice", commandtest.Autocomplete("mdb", commandtest.Subcommand("results", commandtest.Focused(commandtest.String("id", questionId)))))
+
+	calls := h.Session.Calls()
+	choices := calls[len(calls)-1].Response.Data.Choices
+	require.NotEmpty(t, choices)
+	assert.Equal(t, questionId, choices[0].Value)
+}
+
+func TestCounterOfferBounds(t *testing.T) {
+	h, store := newBot(t, mdb.WithCounterOfferBounds(10, 1000))
+	questionId := askQuestion(t, h, store)
+
+	messages := h.Run("alice", mdbCommand("answer", commandtest.String("choice", "maybe..."), commandtest.Number("counter-offer", 1001)))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "`counter-offer` can't be more than 1000")
+
+	messages = h.Run("alice", mdbCommand("answer", commandtest.String("choice", "maybe..."), commandtest.Number("counter-offer", 5)))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "`counter-offer` has to be at least 10")
+
+	messages = h.Run("alice", commandtest.Submit(command.CustomId("mdb-counter-offer", questionId), map[string]string{"counter-offer": "$2,000"}))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "between 10 and 1000")
+
+	for _, offer := range []string{"NaN", "Inf", "-Inf"} {
+		messages = h.Run("alice", commandtest.Submit(command.CustomId("mdb-counter-offer", questionId), map[string]string{"counter-offer": offer}))
+		require.Len(t, messages, 1)
+		assert.Contains(t, messages[0].Content, "between 10 and 1000", offer)
+	}
+
+	messages = h.Run("alice", commandtest.Submit(command.CustomId("mdb-counter-offer", questionId), map[string]string{"counter-offer": "$500"}))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Content, "$500")
+}
+
+func TestFeatures(t *testing.T) {
+	t.Run("without buttons", func(t *testing.T) {
+		h, _ := newBot(t, mdb.WithoutButtons())
+
+		messages := h.Run("asker", mdbCommand("question"))
+		require.Len(t, messages, 1)
+		assert.Contains(t, messages[0].Content, "You get a million dollars, but...")
+		assert.Empty(t, messages[0].Components)
+	})
+
+	t.Run("without leaderboard or results", func(t *testing.T) {
+		store, err := storage.NewLocalStorage(t.TempDir()+"/stats.json", storage.WithAutosaveInterval(0))
+		require.NoError(t, err)
+		bot := mdb.NewMillionDollarBot(store, mdb.WithoutLeaderboard(), mdb.WithoutResults())
+		t.Cleanup(func() { bot.Close() })
+
+		var subcommands []string
+		for _, option := range bot.Commands[0].CommandInfo.Options {
+			subcommands = append(subcommands, option.Name)
+		}
+		assert.ElementsMatch(t, []string{"answer", "question", "stats"}, subcommands)
+	})
+}
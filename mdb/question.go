@@ -2,8 +2,9 @@ package mdb
 
 import (
 	"fmt"
-	"log"
+	"strings"
 
+	"github.com/Scraniel/go-roboto-sensei/command"
 	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
 	"github.com/bwmarrin/discordgo"
 )
@@ -14,6 +15,10 @@ const (
 	questionCommandId = "question"
 
 	questionFormat = "You get a million dollars, but... %s (ID: `%s`)"
+
+	// recentQuestionLimit is how many of the questions most recently asked in a channel are suggested when typing out a
+	// question ID.
+	recentQuestionLimit = 100
 )
 
 var (
@@ -27,17 +32,60 @@ var (
 
 type QuestionHandler struct {
 	storage storage.Storage
+	// contact is who to tell when the questions run out.
+	contact string
+	// buttons is whether questions come with buttons to answer them.
+	buttons bool
 }
 
-func (h *QuestionHandler) Handle(caller *discordgo.Member, options map[string]interface{}) string {
+// Handle asks a new question, with buttons underneath to answer it unless they're turned off.
+func (h *QuestionHandler) Handle(req *command.Request) (*command.Response, error) {
+	// Picking a question marks it as asked.
+	if err := req.Commit(); err != nil {
+		return nil, err
+	}
 
-	question, err := h.storage.GetUnaskedQuestion()
+	question, err := h.storage.GetUnaskedQuestion(req.GuildID, req.Member.User.ID, req.ChannelID)
 	if err == storage.ErrNoMoreRemainingQuestions {
-		return "Whoops, all the prewritten questions have been asked! Tell Danny to add more!"
+		return command.Message(fmt.Sprintf("Whoops, all the prewritten questions have been asked! Tell %s to add more!", h.contact)), nil
 	} else if err != nil {
-		log.Printf("unknown error from storage: %v", err)
-		return "You shouldn't be able to get here!! Tell Danny please!"
+		return nil, fmt.Errorf("GetUnaskedQuestion returned an error: %w", err)
+	}
+
+	response := command.Message(fmt.Sprintf(questionFormat, question.Text, question.Id))
+	if h.buttons {
+		response.Components = answerButtons(question.Id)
+	}
+
+	return response, nil
+}
+
+// suggestQuestions suggests the questions most recently asked in the channel for the question ID option being typed,
+// matching what's been typed against their IDs and text.
+func suggestQuestions(store storage.Storage, req *command.Request, focused *discordgo.ApplicationCommandInteractionDataOption) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	if focused.Name != questionIdOptionId {
+		return nil, nil
+	}
+
+	questions, err := store.GetRecentQuestions(req.GuildID, req.ChannelID, recentQuestionLimit)
+	if err != nil {
+		return nil, fmt.Errorf("GetRecentQuestions returned an error: %w", err)
+	}
+
+	typed, _ := focused.Value.(string)
+	typed = strings.ToLower(strings.TrimSpace(typed))
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, question := range questions {
+		if !strings.HasPrefix(question.Id, typed) && !strings.Contains(strings.ToLower(question.Text), typed) {
+			continue
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  fmt.Sprintf("%s: %s", question.Id, question.Text),
+			Value: question.Id,
+		})
 	}
 
-	return fmt.Sprintf(questionFormat, question.Text, question.Id)
+	return choices, nil
 }
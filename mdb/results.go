@@ -0,0 +1,143 @@
+package mdb
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	resultsCommandVersion = "0.2"
+	resultsCommandId      = "results"
+)
+
+var (
+	resultsCommandInfo = &discordgo.ApplicationCommand{
+		Version:     resultsCommandVersion,
+		Type:        discordgo.ChatApplicationCommand,
+		Name:        resultsCommandId,
+		Description: "How did everyone answer a question?",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         questionIdOptionId,
+				Description:  "ID of a previously asked question.",
+				Required:     true,
+				Autocomplete: true,
+			},
+		},
+	}
+)
+
+// resultsOptions are the options of `/results`.
+type resultsOptions struct {
+	QuestionId string `option:"id"`
+}
+
+type ResultsHandler struct {
+	storage storage.Storage
+}
+
+func (h *ResultsHandler) Handle(req *command.Request) (*command.Response, error) {
+	var options resultsOptions
+	if err := req.Decode(&options); err != nil {
+		return nil, err
+	}
+
+	questionId := options.QuestionId
+
+	if asked, err := h.storage.HasQuestionBeenAsked(req.GuildID, questionId); err != nil {
+		return nil, fmt.Errorf("HasQuestionBeenAsked returned an error: %w", err)
+	} else if !asked {
+		return command.Ephemeral(fmt.Sprintf("No question with that ID has been asked! Try `%s` for a new qustion.", slashCommand(questionCommandId))), nil
+	}
+
+	question, err := h.storage.GetQuestion(questionId)
+	if err != nil {
+		return nil, fmt.Errorf("GetQuestion returned an error: %w", err)
+	}
+
+	answers, err := h.storage.GetAnswers(req.GuildID, questionId)
+	if err != nil {
+		return nil, fmt.Errorf("GetAnswers returned an error: %w", err)
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**Results for question `%s`**\n", questionId))
+	response.WriteString(fmt.Sprintf("> You get a million dollars, but... %s\n\n", question.Text))
+
+	if len(answers) == 0 {
+		response.WriteString(fmt.Sprintf("No one has answered yet! Be the first with `%s`.", slashCommand(answerCommandId)))
+		return &command.Response{Content: response.String(), AllowedMentions: command.NoMentions()}, nil
+	}
+
+	results := tallyResults(answers)
+	response.WriteString(fmt.Sprintf("%d answer(s): %d `yes`, %d `no`, %d `maybe...`\n", len(answers), results.yes, results.no, len(results.counterOffers)))
+
+	if len(results.counterOffers) > 0 {
+		printer := message.NewPrinter(language.English)
+		lowest, highest := results.counterOffers[0], results.counterOffers[len(results.counterOffers)-1]
+
+		response.WriteString(printer.Sprintf("Median counter-offer: $%.0f\n", results.medianCounterOffer()))
+		response.WriteString(printer.Sprintf("Counter-offers ranged from $%d (%s) to $%d (%s)\n",
+			lowest.Offer, (&discordgo.User{ID: lowest.PlayerId}).Mention(),
+			highest.Offer, (&discordgo.User{ID: highest.PlayerId}).Mention()))
+	}
+
+	return &command.Response{Content: response.String(), AllowedMentions: command.NoMentions()}, nil
+}
+
+// Autocomplete suggests questions recently asked in the channel for the `id` option.
+func (h *ResultsHandler) Autocomplete(req *command.Request, focused *discordgo.ApplicationCommandInteractionDataOption) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	return suggestQuestions(h.storage, req, focused)
+}
+
+// questionResults breaks the answers to a question down by what was answered.
+type questionResults struct {
+	yes, no int
+
+	// counterOffers is every `maybe...` answer, from lowest to highest offer.
+	counterOffers []storage.Answer
+}
+
+func tallyResults(answers []storage.Answer) questionResults {
+	var results questionResults
+	for _, answer := range answers {
+		switch answer.Offer {
+		case OneMillion:
+			results.yes++
+		case 0:
+			results.no++
+		default:
+			results.counterOffers = append(results.counterOffers, answer)
+		}
+	}
+
+	// Stable so that ties keep whoever has the lowest player ID first.
+	slices.SortStableFunc(results.counterOffers, func(a, b storage.Answer) int {
+		return cmp.Compare(a.Offer, b.Offer)
+	})
+
+	return results
+}
+
+func (r questionResults) medianCounterOffer() float64 {
+	count := len(r.counterOffers)
+	if count == 0 {
+		return 0
+	}
+
+	if count%2 == 1 {
+		return float64(r.counterOffers[count/2].Offer)
+	}
+
+	return (float64(r.counterOffers[count/2-1].Offer) + float64(r.counterOffers[count/2].Offer)) / 2
+}
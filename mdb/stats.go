@@ -0,0 +1,166 @@
+package mdb
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/Scraniel/go-roboto-sensei/command"
+	"github.com/Scraniel/go-roboto-sensei/mdb/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	statsCommandVersion = "0.1"
+	statsCommandId      = "stats"
+
+	userOptionId = "user"
+	pageOptionId = "page"
+
+	statsPageSize = 10
+
+	// Keeps a full page of answers well under Discord's 2000 character message limit.
+	maxQuestionPreviewLength = 100
+)
+
+var (
+	// Unfortunately must be a variable instead of a constant so that it's addressable.
+	minPage = float64(1)
+
+	statsCommandInfo = &discordgo.ApplicationCommand{
+		Version:     statsCommandVersion,
+		Type:        discordgo.ChatApplicationCommand,
+		Name:        statsCommandId,
+		Description: "How much money have you made, and what did you have to do for it?",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        userOptionId,
+				Description: "Optional: whose stats to show. Defaults to you.",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        pageOptionId,
+				Description: "Optional: which page of answers to show. Defaults to the first.",
+				MinValue:    &minPage,
+				Required:    false,
+			},
+		},
+	}
+)
+
+// statsOptions are the options of `/stats`.
+type statsOptions struct {
+	UserId string `option:"user"`
+	Page   int    `option:"page"`
+}
+
+type StatsHandler struct {
+	storage storage.Storage
+}
+
+func (h *StatsHandler) Handle(req *command.Request) (*command.Response, error) {
+	options := statsOptions{Page: 1}
+	if err := req.Decode(&options); err != nil {
+		return nil, err
+	}
+
+	player, page := req.Member.User, options.Page
+	if options.UserId != "" {
+		player = &discordgo.User{ID: options.UserId}
+	}
+
+	stats, err := h.storage.GetStats(req.GuildID, player.ID)
+	if err != nil {
+		return nil, fmt.Errorf("GetStats returned an error: %w", err)
+	}
+
+	if len(stats.Answered) == 0 {
+		return &command.Response{Content: fmt.Sprintf("%s hasn't answered any questions yet! Try `%s` to get one.", player.Mention(), slashCommand(questionCommandId)), AllowedMentions: command.NoMentions()}, nil
+	}
+
+	questionIds := slices.SortedFunc(maps.Keys(stats.Answered), compareQuestionIds)
+	pages := (len(questionIds) + statsPageSize - 1) / statsPageSize
+	if page > pages {
+		return command.Ephemeral(fmt.Sprintf("%s only has %d page(s) of answers!", player.Mention(), pages)), nil
+	}
+
+	printer := message.NewPrinter(language.English)
+	summary := summarize(stats)
+
+	var response strings.Builder
+	response.WriteString(printer.Sprintf("**Stats for %s**\n", player.Mention()))
+	response.WriteString(printer.Sprintf("Net worth: $%.2f million\n", float64(stats.GetTotalMoney())/float64(OneMillion)))
+	response.WriteString(printer.Sprintf("Answered %d question(s): %d `yes`, %d `no`, %d `maybe...`", len(stats.Answered), summary.yes, summary.no, summary.maybe))
+	if summary.maybe > 0 {
+		response.WriteString(printer.Sprintf(" (average counter-offer: $%.0f)", summary.averageCounterOffer))
+	}
+	response.WriteString(printer.Sprintf("\n\n**Answers (page %d of %d)**\n", page, pages))
+
+	start := (page - 1) * statsPageSize
+	for _, questionId := range questionIds[start:min(start+statsPageSize, len(questionIds))] {
+		text := "(this question doesn't exist anymore)"
+		if question, err := h.storage.GetQuestion(questionId); err == nil {
+			text = truncate(question.Text, maxQuestionPreviewLength)
+		} else if err != storage.ErrNoSuchQuestionId {
+			log.Printf("GetQuestion returned an error: %v.", err)
+		}
+
+		response.WriteString(fmt.Sprintf("- `%s` %s → `%s`\n", questionId, text, describeOffer(stats.Answered[questionId])))
+	}
+
+	return &command.Response{Content: response.String(), AllowedMentions: command.NoMentions()}, nil
+}
+
+// statsSummary breaks a player's answers down by what they answered.
+type statsSummary struct {
+	yes, no, maybe int
+
+	// averageCounterOffer is the average of every `maybe...` answer, in dollars.
+	averageCounterOffer float64
+}
+
+func summarize(stats storage.PlayerStats) statsSummary {
+	var summary statsSummary
+	var counterOffers uint
+	for _, offer := range stats.Answered {
+		switch offer {
+		case OneMillion:
+			summary.yes++
+		case 0:
+			summary.no++
+		default:
+			summary.maybe++
+			counterOffers += offer
+		}
+	}
+
+	if summary.maybe > 0 {
+		summary.averageCounterOffer = float64(counterOffers) / float64(summary.maybe)
+	}
+
+	return summary
+}
+
+// compareQuestionIds orders question IDs numerically. They're all non-negative integers without leading zeros, so
+// shorter IDs are always smaller.
+func compareQuestionIds(a, b string) int {
+	return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+}
+
+// truncate shortens text to at most length runes, adding an ellipsis if anything was cut off.
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	return string(runes[:length-1]) + "…"
+}
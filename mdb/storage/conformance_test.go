@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend opens a Storage saving to path. Opening the same path again should see everything saved before Close.
+type backend func(path string) (Storage, error)
+
+func TestLocalStorageConformance(t *testing.T) {
+	testConformance(t, func(path string) (Storage, error) {
+		return NewLocalStorage(path, WithAutosaveInterval(0))
+	})
+}
+
+func TestSQLiteStorageConformance(t *testing.T) {
+	testConformance(t, func(path string) (Storage, error) {
+		return NewSQLiteStorage(path)
+	})
+}
+
+// testConformance checks the behaviour every Storage implementation has to share.
+func testConformance(t *testing.T, open backend) {
+	newStorage := func(t *testing.T) (Storage, string) {
+		path := t.TempDir() + "/storage"
+		storage, err := open(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { storage.Close() })
+
+		return storage, path
+	}
+
+	t.Run("unknown player has no stats", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		stats, err := storage.GetStats("guild", "nobody")
+		assert.NoError(t, err)
+		assert.Empty(t, stats.Answered)
+		assert.Zero(t, stats.GetTotalMoney())
+	})
+
+	t.Run("updates stats", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		_, err := storage.UpdateStats("guild", "1", "player", 10)
+		assert.NoError(t, err)
+		_, err = storage.UpdateStats("guild", "2", "player", 20)
+		assert.NoError(t, err)
+		stats, err := storage.UpdateStats("guild", "1", "player", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]uint{"1": 5, "2": 20}, stats.Answered)
+
+		stats, err = storage.GetStats("guild", "player")
+		assert.NoError(t, err)
+		assert.Equal(t, uint(25), stats.GetTotalMoney())
+
+		stats, err = storage.GetStats("guild", "someone else")
+		assert.NoError(t, err)
+		assert.Empty(t, stats.Answered)
+	})
+
+	t.Run("keeps guilds separate", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		question, err := storage.GetUnaskedQuestion("guild", "asker", "channel")
+		require.NoError(t, err)
+		_, err = storage.UpdateStats("guild", question.Id, "player", 1000)
+		require.NoError(t, err)
+
+		stats, err := storage.GetStats("other guild", "player")
+		assert.NoError(t, err)
+		assert.Empty(t, stats.Answered)
+
+		asked, err := storage.HasQuestionBeenAsked("other guild", question.Id)
+		assert.NoError(t, err)
+		assert.False(t, asked)
+
+		_, err = storage.GetCurrentQuestionId("other guild", "channel")
+		assert.ErrorIs(t, err, ErrNoQuestionsAsked)
+	})
+
+	t.Run("tracks current question per channel", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		first, err := storage.GetUnaskedQuestion("guild", "asker", "channel")
+		require.NoError(t, err)
+		second, err := storage.GetUnaskedQuestion("guild", "asker", "thread")
+		require.NoError(t, err)
+
+		current, err := storage.GetCurrentQuestionId("guild", "channel")
+		assert.NoError(t, err)
+		assert.Equal(t, first.Id, current)
+
+		current, err = storage.GetCurrentQuestionId("guild", "thread")
+		assert.NoError(t, err)
+		assert.Equal(t, second.Id, current)
+
+		_, err = storage.GetCurrentQuestionId("guild", "quiet channel")
+		assert.ErrorIs(t, err, ErrNoQuestionsAsked)
+	})
+
+	t.Run("gets recent questions per channel", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		var asked []Question
+		for _, channel := range []string{"channel", "thread", "channel", "channel"} {
+			question, err := storage.GetUnaskedQuestion("guild", "asker", channel)
+			require.NoError(t, err)
+			asked = append(asked, question)
+		}
+
+		recent, err := storage.GetRecentQuestions("guild", "channel", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []Question{asked[3], asked[2], asked[0]}, recent)
+
+		recent, err = storage.GetRecentQuestions("guild", "channel", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []Question{asked[3], asked[2]}, recent)
+
+		recent, err = storage.GetRecentQuestions("guild", "quiet channel", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, recent)
+
+		recent, err = storage.GetRecentQuestions("other guild", "channel", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, recent)
+	})
+
+	t.Run("ranks players", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		answers := map[string]map[string]uint{
+			"alice": {"1": OneMillion, "2": 0, "3": 250},
+			"bob":   {"1": 0, "2": 0},
+			"carol": {"1": OneMillion, "2": 500},
+			"dave":  {"1": 250},
+		}
+		for player, answered := range answers {
+			for questionId, offer := range answered {
+				_, err := storage.UpdateStats("guild", questionId, player, offer)
+				require.NoError(t, err)
+			}
+		}
+
+		expected := map[LeaderboardMetric][]LeaderboardEntry{
+			MetricTotalMoney: {
+				{PlayerId: "carol", Score: OneMillion + 500, Rank: 1},
+				{PlayerId: "alice", Score: OneMillion + 250, Rank: 2},
+				{PlayerId: "dave", Score: 250, Rank: 3},
+				{PlayerId: "bob", Score: 0, Rank: 4},
+			},
+			MetricNoAnswers: {
+				{PlayerId: "bob", Score: 2, Rank: 1},
+				{PlayerId: "alice", Score: 1, Rank: 2},
+			},
+			MetricHighestCounterOffer: {
+				{PlayerId: "carol", Score: 500, Rank: 1},
+				{PlayerId: "alice", Score: 250, Rank: 2},
+				{PlayerId: "dave", Score: 250, Rank: 2},
+			},
+			MetricQuestionsAnswered: {
+				{PlayerId: "alice", Score: 3, Rank: 1},
+				{PlayerId: "bob", Score: 2, Rank: 2},
+				{PlayerId: "carol", Score: 2, Rank: 2},
+				{PlayerId: "dave", Score: 1, Rank: 4},
+			},
+		}
+
+		for metric, entries := range expected {
+			actual, total, err := storage.GetLeaderboard("guild", metric, 0, 10)
+			assert.NoError(t, err)
+			assert.Equal(t, entries, actual, metric)
+			assert.Equal(t, len(entries), total, metric)
+
+			page, total, err := storage.GetLeaderboard("guild", metric, 1, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, entries[1:2], page, metric)
+			assert.Equal(t, len(entries), total, metric)
+		}
+
+		empty, total, err := storage.GetLeaderboard("other guild", MetricTotalMoney, 0, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, empty)
+		assert.Zero(t, total)
+
+		_, _, err = storage.GetLeaderboard("guild", "tallest", 0, 10)
+		assert.ErrorIs(t, err, ErrUnknownMetric)
+	})
+
+	t.Run("gets answers by question", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		for player, offer := range map[string]uint{"carol": 5, "alice": OneMillion, "bob": 0} {
+			_, err := storage.UpdateStats("guild", "1", player, offer)
+			require.NoError(t, err)
+		}
+		_, err := storage.UpdateStats("guild", "1", "bob", 7)
+		require.NoError(t, err)
+		_, err = storage.UpdateStats("guild", "2", "alice", 0)
+		require.NoError(t, err)
+		_, err = storage.UpdateStats("other guild", "1", "dave", 0)
+		require.NoError(t, err)
+
+		answers, err := storage.GetAnswers("guild", "1")
+		assert.NoError(t, err)
+		assert.Equal(t, []Answer{
+			{PlayerId: "alice", Offer: OneMillion},
+			{PlayerId: "bob", Offer: 7},
+			{PlayerId: "carol", Offer: 5},
+		}, answers)
+
+		answers, err = storage.GetAnswers("guild", "3")
+		assert.NoError(t, err)
+		assert.Empty(t, answers)
+	})
+
+	t.Run("gets questions by id", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		question, err := storage.GetQuestion("0")
+		assert.NoError(t, err)
+		assert.Equal(t, "0", question.Id)
+		assert.NotEmpty(t, question.Text)
+
+		_, err = storage.GetQuestion("not a question")
+		assert.ErrorIs(t, err, ErrNoSuchQuestionId)
+	})
+
+	t.Run("no questions asked", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		_, err := storage.GetCurrentQuestionId("guild", "channel")
+		assert.ErrorIs(t, err, ErrNoQuestionsAsked)
+
+		asked, err := storage.HasQuestionBeenAsked("guild", "0")
+		assert.NoError(t, err)
+		assert.False(t, asked)
+	})
+
+	t.Run("asks every question exactly once", func(t *testing.T) {
+		storage, _ := newStorage(t)
+
+		seen := map[string]bool{}
+		for {
+			question, err := storage.GetUnaskedQuestion("guild", "asker", "channel")
+			if err == ErrNoMoreRemainingQuestions {
+				break
+			}
+			require.NoError(t, err)
+			require.False(t, seen[question.Id], "question %s asked twice", question.Id)
+			seen[question.Id] = true
+
+			expected, err := storage.GetQuestion(question.Id)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, question)
+
+			asked, err := storage.HasQuestionBeenAsked("guild", question.Id)
+			assert.NoError(t, err)
+			assert.True(t, asked)
+
+			current, err := storage.GetCurrentQuestionId("guild", "channel")
+			assert.NoError(t, err)
+			assert.Equal(t, question.Id, current)
+		}
+
+		questions, err := loadQuestions()
+		assert.NoError(t, err)
+		assert.Len(t, seen, len(questions))
+	})
+
+	t.Run("survives reopening", func(t *testing.T) {
+		storage, path := newStorage(t)
+
+		question, err := storage.GetUnaskedQuestion("guild", "asker", "channel")
+		require.NoError(t, err)
+		_, err = storage.UpdateStats("guild", question.Id, "player", 1000)
+		require.NoError(t, err)
+		require.NoError(t, storage.Close())
+
+		reopened, err := open(path)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		stats, err := reopened.GetStats("guild", "player")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]uint{question.Id: 1000}, stats.Answered)
+
+		current, err := reopened.GetCurrentQuestionId("guild", "channel")
+		assert.NoError(t, err)
+		assert.Equal(t, question.Id, current)
+
+		asked, err := reopened.HasQuestionBeenAsked("guild", question.Id)
+		assert.NoError(t, err)
+		assert.True(t, asked)
+	})
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// statsFile is the format everything LocalStorage knows is saved to disk in. It's wrapped in a versionedStatsFile when
+// saved - see migrate.go before changing it.
+type statsFile struct {
+	Guilds map[string]guildStatsFile `json:"guilds"`
+}
+
+// guildStatsFile is everything saved for a single guild. The current question in each channel is the last one asked
+// there, so it isn't saved separately.
+type guildStatsFile struct {
+	Players        map[string]PlayerStats `json:"players"`
+	AskedQuestions []AskedQuestion        `json:"askedQuestions"`
+}
+
+// saveStats atomically replaces the file at filePath with stats. The new contents are written and synced to a temp file
+// in the same directory, the existing file is copied into the backups and the temp file is then renamed over it, so a
+// crash part way through always leaves a whole stats file behind - the old one or the new one.
+func saveStats(stats statsFile, filePath string, overwrite bool, backups int) error {
+	if _, err := os.Stat(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error checking file on save: %v", err)
+	} else if err == nil && !overwrite {
+		return errors.New("file exists and overwrite was set to false")
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temp file: %v", err)
+	}
+
+	// Once the rename succeeds this is a no-op, otherwise it cleans up after a failed save.
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	versioned, err := encodeStatsFile(stats)
+	if err != nil {
+		return fmt.Errorf("error encoding stats: %v", err)
+	}
+
+	encoder := json.NewEncoder(file)
+	if err = encoder.Encode(versioned); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("error syncing file: %v", err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("error closing file: %v", err)
+	}
+
+	if err = rotateBackups(filePath, backups); err != nil {
+		return fmt.Errorf("error rotating backups: %v", err)
+	}
+
+	if err = os.Rename(file.Name(), filePath); err != nil {
+		return fmt.Errorf("error replacing file: %v", err)
+	}
+
+	if err = syncDir(filepath.Dir(filePath)); err != nil {
+		return fmt.Errorf("error syncing directory: %v", err)
+	}
+
+	return nil
+}
+
+// syncDir syncs the directory at path, so a rename in it survives a crash.
+func syncDir(path string) error {
+	// Windows can't sync directories, and doesn't need to for renames to stick.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+// backupPath returns the path of the nth most recent backup of filePath.
+func backupPath(filePath string, n int) string {
+	return fmt.Sprintf("%s.%d", filePath, n)
+}
+
+// rotateBackups shifts every backup of filePath back by one, dropping the oldest, and copies filePath into the newest
+// backup slot. filePath itself is left in place, so it's only ever replaced by the rename in saveStats.
+func rotateBackups(filePath string, backups int) error {
+	if backups <= 0 {
+		return nil
+	}
+
+	for n := backups - 1; n >= 1; n-- {
+		if err := os.Rename(backupPath(filePath, n), backupPath(filePath, n+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	// With a single backup, there was nothing to shift out of the way.
+	newest := backupPath(filePath, 1)
+	if err := os.Remove(newest); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+		// Nothing's been saved yet.
+		return nil
+	}
+
+	// A hard link is a free copy, since filePath is only ever replaced, never written to. Not every filesystem has them.
+	if err := os.Link(filePath, newest); err != nil {
+		return copyFile(filePath, newest)
+	}
+
+	return nil
+}
+
+// copyFile copies the file at from to to, syncing it to disk.
+func copyFile(from, to string) error {
+	contents, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(contents); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// loadStatsOrBackup loads the stats at filePath, falling back to the newest of its backups that can be loaded. If none
+// can be, the error from filePath is returned. The schema version of whichever file was loaded is also returned.
+func loadStatsOrBackup(filePath string, backups int) (statsFile, int, error) {
+	stats, version, err := loadStats(filePath)
+	if err == nil {
+		return stats, version, nil
+	}
+
+	for n := 1; n <= backups; n++ {
+		path := backupPath(filePath, n)
+		backupStats, backupVersion, backupErr := loadStats(path)
+		if backupErr == nil {
+			log.Printf("couldn't load %s (%v), restored stats from backup %s", filePath, err, path)
+			return backupStats, backupVersion, nil
+		} else if !errors.Is(backupErr, os.ErrNotExist) {
+			log.Printf("couldn't load backup %s: %v", path, backupErr)
+		}
+	}
+
+	return statsFile{}, 0, err
+}
+
+func loadStats(filePath string) (statsFile, int, error) {
+	serialized, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return statsFile{}, 0, err
+	} else if err != nil {
+		return statsFile{}, 0, fmt.Errorf("error checking file on load: %v", err)
+	}
+
+	return decodeStatsFile(serialized)
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+)
+
+// OneMillion is the offer recorded when a player answers `yes`. Any other offer above 0 is a counter-offer.
+const OneMillion = uint(1000000)
+
+// LeaderboardMetric is something players in a guild can be ranked by.
+type LeaderboardMetric string
+
+const (
+	MetricTotalMoney          LeaderboardMetric = "total-money"
+	MetricNoAnswers           LeaderboardMetric = "no-answers"
+	MetricHighestCounterOffer LeaderboardMetric = "highest-counter-offer"
+	MetricQuestionsAnswered   LeaderboardMetric = "questions-answered"
+)
+
+// LeaderboardMetrics is every metric GetLeaderboard understands.
+var LeaderboardMetrics = []LeaderboardMetric{
+	MetricTotalMoney,
+	MetricNoAnswers,
+	MetricHighestCounterOffer,
+	MetricQuestionsAnswered,
+}
+
+// LeaderboardEntry is a single player's place on a leaderboard. Players with the same score share a rank, and the next
+// rank skips ahead (1, 2, 2, 4).
+type LeaderboardEntry struct {
+	PlayerId string
+	Score    uint
+	Rank     int
+}
+
+// Score returns how the player ranks by metric.
+func (s PlayerStats) Score(metric LeaderboardMetric) uint {
+	var score uint
+	for _, offer := range s.Answered {
+		switch metric {
+		case MetricTotalMoney:
+			score += offer
+		case MetricNoAnswers:
+			if offer == 0 {
+				score++
+			}
+		case MetricHighestCounterOffer:
+			if offer != 0 && offer != OneMillion {
+				score = max(score, offer)
+			}
+		case MetricQuestionsAnswered:
+			score++
+		}
+	}
+
+	return score
+}
+
+// ranksZeroScores is whether players scoring 0 by metric still show up on its leaderboard. Nobody wants to see a list
+// of people who've never said `no`.
+func (m LeaderboardMetric) ranksZeroScores() bool {
+	return m == MetricTotalMoney || m == MetricQuestionsAnswered
+}
+
+// rankPlayers sorts everyone in players onto a leaderboard for metric.
+func rankPlayers(players map[string]PlayerStats, metric LeaderboardMetric) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(players))
+	for playerId, stats := range players {
+		if len(stats.Answered) == 0 {
+			continue
+		}
+
+		score := stats.Score(metric)
+		if score == 0 && !metric.ranksZeroScores() {
+			continue
+		}
+
+		entries = append(entries, LeaderboardEntry{PlayerId: playerId, Score: score})
+	}
+
+	slices.SortFunc(entries, func(a, b LeaderboardEntry) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.PlayerId, b.PlayerId))
+	})
+
+	for i := range entries {
+		if i > 0 && entries[i].Score == entries[i-1].Score {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// schemaVersion is the version of statsFile this code reads and writes. Whenever statsFile changes, bump it and add a
+// migration from the previous version to migrations.
+//
+// History:
+//   - 0: a bare map of player ID to PlayerStats, with no envelope.
+//   - 1: players, the asked-question log and the most recent question ID. Saved without an envelope until the envelope
+//     was introduced.
+//   - 2: everything from version 1, kept separately for each guild.
+//   - 3: the most recent question ID is dropped - the current question is tracked per channel from the asked-question
+//     log instead.
+const schemaVersion = 3
+
+// legacyGuildKey is the guild that everything saved before version 2 is moved into, until LocalStorage is told which
+// guild it actually belongs to.
+const legacyGuildKey = ""
+
+// migration upgrades the data of a stats file by a single schema version.
+type migration func(data json.RawMessage) (json.RawMessage, error)
+
+// migrations[n] upgrades data from schema version n to version n+1.
+var migrations = []migration{
+	0: migratePlayersOnly,
+	1: migrateToGuilds,
+	2: migrateToChannels,
+}
+
+// versionedStatsFile is the envelope stats files are saved in, so we know how to read them back in.
+type versionedStatsFile struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decodeStatsFile reads a stats file saved with any schema version, upgrading it to the current one. It also returns
+// the version the file was saved with.
+func decodeStatsFile(serialized []byte) (statsFile, int, error) {
+	saved, err := detectVersion(serialized)
+	if err != nil {
+		return statsFile{}, 0, err
+	}
+
+	if saved.Version > schemaVersion {
+		return statsFile{}, 0, fmt.Errorf("stats were saved with schema version %d but we only understand up to %d", saved.Version, schemaVersion)
+	}
+
+	data := saved.Data
+	for version := saved.Version; version < schemaVersion; version++ {
+		if data, err = migrations[version](data); err != nil {
+			return statsFile{}, 0, fmt.Errorf("error migrating from schema version %d: %w", version, err)
+		}
+	}
+
+	var stats statsFile
+	if err = json.Unmarshal(data, &stats); err != nil {
+		return statsFile{}, 0, fmt.Errorf("error decoding json: %v", err)
+	}
+
+	if stats.Guilds == nil {
+		stats.Guilds = map[string]guildStatsFile{}
+	}
+
+	return stats, saved.Version, nil
+}
+
+// encodeStatsFile wraps stats in an envelope with the current schema version.
+func encodeStatsFile(stats statsFile) (versionedStatsFile, error) {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return versionedStatsFile{}, err
+	}
+
+	return versionedStatsFile{Version: schemaVersion, Data: data}, nil
+}
+
+// detectVersion works out which schema version serialized was saved with, including files saved before the envelope.
+func detectVersion(serialized []byte) (versionedStatsFile, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(serialized, &fields); err != nil {
+		return versionedStatsFile{}, fmt.Errorf("error decoding json: %v", err)
+	}
+
+	if _, ok := fields["version"]; ok {
+		var saved versionedStatsFile
+		if err := json.Unmarshal(serialized, &saved); err != nil {
+			return versionedStatsFile{}, fmt.Errorf("error decoding json: %v", err)
+		}
+
+		return saved, nil
+	}
+
+	if _, ok := fields["players"]; ok {
+		return versionedStatsFile{Version: 1, Data: serialized}, nil
+	}
+
+	return versionedStatsFile{Version: 0, Data: serialized}, nil
+}
+
+// migratePlayersOnly moves the bare player map into a version 1 file. Answers were the only record of which questions had been
+// asked, so the asked-question log is rebuilt from them.
+func migratePlayersOnly(data json.RawMessage) (json.RawMessage, error) {
+	var players map[string]PlayerStats
+	if err := json.Unmarshal(data, &players); err != nil {
+		return nil, err
+	}
+
+	var answered []string
+	seen := map[string]bool{}
+	for player := range players {
+		for questionId := range players[player].Answered {
+			if !seen[questionId] {
+				seen[questionId] = true
+				answered = append(answered, questionId)
+			}
+		}
+	}
+
+	slices.Sort(answered)
+	asked := make([]AskedQuestion, 0, len(answered))
+	for _, questionId := range answered {
+		asked = append(asked, AskedQuestion{Id: questionId})
+	}
+
+	return json.Marshal(guildStatsFile{
+		Players:        players,
+		AskedQuestions: asked,
+	})
+}
+
+// migrateToGuilds moves everything into legacyGuildKey, since we don't know which guild it came from.
+func migrateToGuilds(data json.RawMessage) (json.RawMessage, error) {
+	return json.Marshal(map[string]any{
+		"guilds": map[string]json.RawMessage{legacyGuildKey: data},
+	})
+}
+
+// migrateToChannels drops each guild's most recent question ID. The asked-question log already says which channel
+// every question was asked in, and questions asked before that was recorded don't belong to any channel.
+func migrateToChannels(data json.RawMessage) (json.RawMessage, error) {
+	var saved struct {
+		Guilds map[string]map[string]json.RawMessage `json:"guilds"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+
+	for _, guild := range saved.Guilds {
+		delete(guild, "mostRecentQuestionId")
+	}
+
+	return json.Marshal(saved)
+}
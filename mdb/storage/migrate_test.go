@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Run("registered for every version", func(t *testing.T) {
+		assert.Len(t, migrations, schemaVersion)
+	})
+
+	// Every schema version we've ever saved should have a fixture here.
+	fixtures := []struct {
+		path     string
+		version  int
+		expected statsFile
+	}{
+		{
+			path:    "./test_stats_v0.json",
+			version: 0,
+			expected: statsFile{
+				Guilds: map[string]guildStatsFile{
+					legacyGuildKey: {
+						Players:        expectedStats,
+						AskedQuestions: expectedAnswered,
+					},
+				},
+			},
+		},
+		{
+			path:    "./test_stats_v1.json",
+			version: 1,
+			expected: statsFile{
+				Guilds: map[string]guildStatsFile{legacyGuildKey: expectedGuild},
+			},
+		},
+		{
+			path:    "./test_stats_v1_envelope.json",
+			version: 1,
+			expected: statsFile{
+				Guilds: map[string]guildStatsFile{legacyGuildKey: expectedGuild},
+			},
+		},
+		{
+			path:    "./test_stats_v2.json",
+			version: 2,
+			expected: statsFile{
+				Guilds: map[string]guildStatsFile{"guild": expectedGuild},
+			},
+		},
+		{
+			path:     "./test_stats.json",
+			version:  schemaVersion,
+			expected: expectedSave,
+		},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run("upgrades "+fixture.path, func(t *testing.T) {
+			actual, version, err := loadStats(fixture.path)
+			assert.NoError(t, err)
+			assert.Equal(t, fixture.version, version)
+			assert.Equal(t, fixture.expected, actual)
+		})
+	}
+
+	t.Run("rejects newer versions", func(t *testing.T) {
+		_, _, err := decodeStatsFile([]byte(`{"version": 1000, "data": {}}`))
+		assert.ErrorContains(t, err, "schema version 1000")
+	})
+
+	t.Run("rewrites upgraded file on close", func(t *testing.T) {
+		savePath := t.TempDir() + testFileName
+		serialized, err := os.ReadFile("./test_stats_v0.json")
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(savePath, serialized, 0644))
+
+		storage, err := NewLocalStorage(savePath, WithAutosaveInterval(0))
+		assert.NoError(t, err)
+		assert.NoError(t, storage.Close())
+
+		_, version, err := loadStats(savePath)
+		assert.NoError(t, err)
+		assert.Equal(t, schemaVersion, version)
+
+		_, version, err = loadStats(backupPath(savePath, 1))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, version)
+	})
+}
+
+func TestLegacyGuild(t *testing.T) {
+	copyFixture := func(t *testing.T) string {
+		savePath := t.TempDir() + testFileName
+		serialized, err := os.ReadFile("./test_stats_v1.json")
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(savePath, serialized, 0644))
+
+		return savePath
+	}
+
+	t.Run("hidden until adopted", func(t *testing.T) {
+		storage, err := NewLocalStorage(copyFixture(t), WithAutosaveInterval(0))
+		assert.NoError(t, err)
+
+		_, err = storage.GetCurrentQuestionId("guild", "general")
+		assert.ErrorIs(t, err, ErrNoQuestionsAsked)
+	})
+
+	t.Run("moved to legacy guild", func(t *testing.T) {
+		savePath := copyFixture(t)
+		storage, err := NewLocalStorage(savePath, WithAutosaveInterval(0), WithLegacyGuild("guild"))
+		assert.NoError(t, err)
+		assert.NoError(t, storage.Close())
+
+		saved, _, err := loadStats(savePath)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedSave, saved)
+
+		current, err := storage.GetCurrentQuestionId("guild", "general")
+		assert.NoError(t, err)
+		assert.Equal(t, "20", current)
+	})
+}
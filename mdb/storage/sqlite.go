@@ -0,0 +1,437 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	// Pure Go, so it still builds with CGO_ENABLED=0.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations[n] upgrades the database from schema version n to version n+1. The database's current version is
+// kept in PRAGMA user_version.
+var sqliteMigrations = []string{
+	0: `
+CREATE TABLE players (
+	id TEXT PRIMARY KEY
+);
+
+CREATE TABLE questions (
+	id   TEXT PRIMARY KEY,
+	text TEXT NOT NULL
+);
+
+CREATE TABLE asks (
+	seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+	question_id TEXT NOT NULL UNIQUE REFERENCES questions (id),
+	asked_by    TEXT NOT NULL,
+	asked_at    TIMESTAMP NOT NULL,
+	channel_id  TEXT NOT NULL
+);
+
+CREATE TABLE answers (
+	player_id   TEXT NOT NULL REFERENCES players (id),
+	question_id TEXT NOT NULL,
+	offer       INTEGER NOT NULL,
+	answered_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (player_id, question_id)
+);
+`,
+	// Everything but the question bank is kept separately for each guild. Existing rows go to the legacy guild until
+	// they're adopted - see adoptLegacyGuild.
+	1: `
+CREATE TABLE guild_asks (
+	seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+	guild_id    TEXT NOT NULL,
+	question_id TEXT NOT NULL REFERENCES questions (id),
+	asked_by    TEXT NOT NULL,
+	asked_at    TIMESTAMP NOT NULL,
+	channel_id  TEXT NOT NULL,
+	UNIQUE (guild_id, question_id)
+);
+
+INSERT INTO guild_asks (seq, guild_id, question_id, asked_by, asked_at, channel_id)
+SELECT seq, '', question_id, asked_by, asked_at, channel_id FROM asks;
+
+DROP TABLE asks;
+ALTER TABLE guild_asks RENAME TO asks;
+
+CREATE TABLE guild_answers (
+	guild_id    TEXT NOT NULL,
+	player_id   TEXT NOT NULL REFERENCES players (id),
+	question_id TEXT NOT NULL,
+	offer       INTEGER NOT NULL,
+	answered_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (guild_id, player_id, question_id)
+);
+
+INSERT INTO guild_answers (guild_id, player_id, question_id, offer, answered_at)
+SELECT '', player_id, question_id, offer, answered_at FROM answers;
+
+DROP TABLE answers;
+ALTER TABLE guild_answers RENAME TO answers;
+`,
+	// The current question in a channel is the last one asked there.
+	2: `
+CREATE INDEX asks_by_channel ON asks (guild_id, channel_id, seq);
+`,
+	3: `
+CREATE INDEX answers_by_question ON answers (guild_id, question_id, player_id);
+`,
+}
+
+// SQLiteStorage is a Storage backed by a SQLite database, with tables for players, questions, asks and answers.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string, opts ...Option) (*SQLiteStorage, error) {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(wal)"},
+	}.Encode()
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("can't open database: %w", err)
+	}
+
+	// SQLite only allows one writer at a time anyways - sharing a single connection keeps every method serialized
+	// without having to retry on SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	storage := &SQLiteStorage{db: db}
+	if err := storage.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't migrate database: %w", err)
+	}
+
+	if err := storage.seedQuestions(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't load questions into database: %w", err)
+	}
+
+	if options.legacyGuildId != "" {
+		if err := storage.adoptLegacyGuild(options.legacyGuildId); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("can't move stats from before guilds were tracked: %w", err)
+		}
+	}
+
+	return storage, nil
+}
+
+// migrate runs every migration the database hasn't had yet.
+func (s *SQLiteStorage) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database is at schema version %d but we only understand up to %d", version, len(sqliteMigrations))
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		err := s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+				return err
+			}
+
+			// PRAGMA doesn't support placeholders.
+			_, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error migrating from schema version %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// seedQuestions makes sure the questions table matches the built in question bank.
+func (s *SQLiteStorage) seedQuestions() error {
+	questions, err := loadQuestions()
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(func(tx *sql.Tx) error {
+		for id, text := range questions {
+			_, err := tx.Exec(`INSERT INTO questions (id, text) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET text = excluded.text`, id, text)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// adoptLegacyGuild moves everything saved before guilds were tracked into guildId, as long as guildId doesn't have
+// anything of its own yet.
+func (s *SQLiteStorage) adoptLegacyGuild(guildId string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		var legacy, existing bool
+		err := tx.QueryRow(`
+SELECT
+	EXISTS (SELECT 1 FROM asks WHERE guild_id = ?) OR EXISTS (SELECT 1 FROM answers WHERE guild_id = ?),
+	EXISTS (SELECT 1 FROM asks WHERE guild_id = ?) OR EXISTS (SELECT 1 FROM answers WHERE guild_id = ?)`,
+			legacyGuildKey, legacyGuildKey, guildId, guildId).Scan(&legacy, &existing)
+		if err != nil || !legacy {
+			return err
+		}
+
+		if existing {
+			log.Printf("guild %s already has stats, leaving stats from before guilds were tracked alone", guildId)
+			return nil
+		}
+
+		log.Printf("moving stats from before guilds were tracked to guild %s", guildId)
+		if _, err := tx.Exec(`UPDATE asks SET guild_id = ? WHERE guild_id = ?`, guildId, legacyGuildKey); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE answers SET guild_id = ? WHERE guild_id = ?`, guildId, legacyGuildKey)
+		return err
+	})
+}
+
+// inTx runs f in a transaction, committing if it succeeds and rolling back otherwise.
+func (s *SQLiteStorage) inTx(f func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func queryStats(q querier, guildId, playerId string) (PlayerStats, error) {
+	rows, err := q.Query(`SELECT question_id, offer FROM answers WHERE guild_id = ? AND player_id = ?`, guildId, playerId)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+	defer rows.Close()
+
+	stats := PlayerStats{Answered: map[string]uint{}}
+	for rows.Next() {
+		var questionId string
+		var offer uint
+		if err := rows.Scan(&questionId, &offer); err != nil {
+			return PlayerStats{}, err
+		}
+		stats.Answered[questionId] = offer
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *SQLiteStorage) GetStats(guildId, playerId string) (PlayerStats, error) {
+	return queryStats(s.db, guildId, playerId)
+}
+
+func (s *SQLiteStorage) UpdateStats(guildId, questionId, playerId string, offer uint) (PlayerStats, error) {
+	var stats PlayerStats
+	err := s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`INSERT INTO players (id) VALUES (?) ON CONFLICT DO NOTHING`, playerId); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`
+INSERT INTO answers (guild_id, player_id, question_id, offer, answered_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (guild_id, player_id, question_id) DO UPDATE SET offer = excluded.offer, answered_at = excluded.answered_at`,
+			guildId, playerId, questionId, offer, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		stats, err = queryStats(tx, guildId, playerId)
+		return err
+	})
+
+	return stats, err
+}
+
+// leaderboardScores is the SQL aggregate over answers that gives each player's score for a metric. These must match
+// PlayerStats.Score.
+var leaderboardScores = map[LeaderboardMetric]string{
+	MetricTotalMoney:          `SUM(offer)`,
+	MetricNoAnswers:           `SUM(offer = 0)`,
+	MetricHighestCounterOffer: fmt.Sprintf(`MAX(CASE WHEN offer NOT IN (0, %d) THEN offer ELSE 0 END)`, OneMillion),
+	MetricQuestionsAnswered:   `COUNT(*)`,
+}
+
+func (s *SQLiteStorage) GetLeaderboard(guildId string, metric LeaderboardMetric, offset, limit int) ([]LeaderboardEntry, int, error) {
+	score, ok := leaderboardScores[metric]
+	if !ok {
+		return nil, 0, ErrUnknownMetric
+	}
+
+	minScore := 1
+	if metric.ranksZeroScores() {
+		minScore = 0
+	}
+
+	// Ranks are worked out over everyone before the page is cut out of them.
+	ranked := fmt.Sprintf(`
+WITH scores AS (
+	SELECT player_id, %s AS score FROM answers WHERE guild_id = ? GROUP BY player_id
+), ranked AS (
+	SELECT player_id, score, RANK() OVER (ORDER BY score DESC) AS rank FROM scores WHERE score >= ?
+)`, score)
+
+	var entries []LeaderboardEntry
+	var total int
+	err := s.inTx(func(tx *sql.Tx) error {
+		if err := tx.QueryRow(ranked+`SELECT COUNT(*) FROM ranked`, guildId, minScore).Scan(&total); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ranked+`SELECT player_id, score, rank FROM ranked ORDER BY score DESC, player_id LIMIT ? OFFSET ?`,
+			guildId, minScore, max(limit, 0), max(offset, 0))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var entry LeaderboardEntry
+			if err := rows.Scan(&entry.PlayerId, &entry.Score, &entry.Rank); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+func (s *SQLiteStorage) GetAnswers(guildId, questionId string) ([]Answer, error) {
+	rows, err := s.db.Query(`SELECT player_id, offer FROM answers WHERE guild_id = ? AND question_id = ? ORDER BY player_id`,
+		guildId, questionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := []Answer{}
+	for rows.Next() {
+		var answer Answer
+		if err := rows.Scan(&answer.PlayerId, &answer.Offer); err != nil {
+			return nil, err
+		}
+		answers = append(answers, answer)
+	}
+
+	return answers, rows.Err()
+}
+
+func (s *SQLiteStorage) GetQuestion(id string) (Question, error) {
+	question := Question{Id: id}
+	err := s.db.QueryRow(`SELECT text FROM questions WHERE id = ?`, id).Scan(&question.Text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Question{}, ErrNoSuchQuestionId
+	} else if err != nil {
+		return Question{}, err
+	}
+
+	return question, nil
+}
+
+func (s *SQLiteStorage) GetCurrentQuestionId(guildId, channelId string) (string, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT question_id FROM asks WHERE guild_id = ? AND channel_id = ? ORDER BY seq DESC LIMIT 1`,
+		guildId, channelId).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoQuestionsAsked
+	}
+
+	return id, err
+}
+
+func (s *SQLiteStorage) GetRecentQuestions(guildId, channelId string, limit int) ([]Question, error) {
+	rows, err := s.db.Query(`
+SELECT questions.id, questions.text FROM asks
+JOIN questions ON questions.id = asks.question_id
+WHERE asks.guild_id = ? AND asks.channel_id = ?
+ORDER BY asks.seq DESC LIMIT ?`, guildId, channelId, max(limit, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []Question
+	for rows.Next() {
+		var question Question
+		if err := rows.Scan(&question.Id, &question.Text); err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+
+	return questions, rows.Err()
+}
+
+// GetUnaskedQuestion picks a random question that hasn't been asked in guildId yet and records that askedBy asked it
+// in channelId.
+func (s *SQLiteStorage) GetUnaskedQuestion(guildId, askedBy, channelId string) (Question, error) {
+	var question Question
+	err := s.inTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+SELECT id, text FROM questions
+WHERE id NOT IN (SELECT question_id FROM asks WHERE guild_id = ?)
+ORDER BY random() LIMIT 1`, guildId).Scan(&question.Id, &question.Text)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoMoreRemainingQuestions
+		} else if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO asks (guild_id, question_id, asked_by, asked_at, channel_id) VALUES (?, ?, ?, ?, ?)`,
+			guildId, question.Id, askedBy, time.Now().UTC(), channelId)
+		return err
+	})
+
+	if err != nil {
+		return Question{}, err
+	}
+
+	return question, nil
+}
+
+func (s *SQLiteStorage) HasQuestionBeenAsked(guildId, id string) (bool, error) {
+	var asked bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM asks WHERE guild_id = ? AND question_id = ?)`, guildId, id).Scan(&asked)
+	return asked, err
+}
+
+// Close closes the database. Every change is written as it happens, so there's nothing to flush.
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteMigrations(t *testing.T) {
+	path := t.TempDir() + "/stats.db"
+
+	// Set up a database the way the first version of SQLiteStorage would have left it.
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = db.Exec(sqliteMigrations[0]+`
+PRAGMA user_version = 1;
+INSERT INTO questions (id, text) VALUES ('3', 'old text');
+INSERT INTO players (id) VALUES ('player');
+INSERT INTO asks (question_id, asked_by, asked_at, channel_id) VALUES ('3', 'player', ?, 'channel');
+INSERT INTO answers (player_id, question_id, offer, answered_at) VALUES ('player', '3', 1000, ?);`,
+		time.Now(), time.Now())
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	storage, err := NewSQLiteStorage(path, WithLegacyGuild("guild"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	var version int
+	assert.NoError(t, storage.db.QueryRow("PRAGMA user_version").Scan(&version))
+	assert.Equal(t, len(sqliteMigrations), version)
+
+	stats, err := storage.GetStats("guild", "player")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint{"3": 1000}, stats.Answered)
+
+	current, err := storage.GetCurrentQuestionId("guild", "channel")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", current)
+
+	question, err := storage.GetQuestion("3")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "old text", question.Text)
+}
@@ -7,10 +7,19 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"math/big"
 	"os"
+	"slices"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAutosaveInterval = 30 * time.Second
+	defaultBackups          = 3
 )
 
 var (
@@ -20,51 +29,230 @@ var (
 	ErrNoSuchQuestionId         = errors.New("no question with that id exists in our question database")
 	ErrNoQuestionsAsked         = errors.New("no questions have been asked yet")
 	ErrNoMoreRemainingQuestions = errors.New("there are no remaining unasked questions")
+	ErrUnknownMetric            = errors.New("no such leaderboard metric")
 )
 
+// Storage is everything the bot remembers. LocalStorage keeps it in memory and saves it to a JSON file, SQLiteStorage
+// keeps it in a SQLite database.
+//
+// Everything but the question bank itself is kept separately for each guild, so servers using the bot don't see each
+// other's questions or stats.
 type Storage interface {
-	GetStats(playerId string) PlayerStats
-	UpdateStats(questionId, playerId string, offer uint) PlayerStats
+	GetStats(guildId, playerId string) (PlayerStats, error)
+	UpdateStats(guildId, questionId, playerId string, offer uint) (PlayerStats, error)
+	// GetLeaderboard ranks the players in guildId by metric, highest score first, skipping the first offset. It also
+	// returns how many players are on the whole leaderboard.
+	GetLeaderboard(guildId string, metric LeaderboardMetric, offset, limit int) ([]LeaderboardEntry, int, error)
+	// GetAnswers returns every answer given to questionId in guildId, ordered by player ID.
+	GetAnswers(guildId, questionId string) ([]Answer, error)
 
 	GetQuestion(id string) (Question, error)
-	GetMostRecentQuestionId() (string, error)
-	GetUnaskedQuestion() (Question, error)
-	HasQuestionBeenAsked(string) bool
+	// GetCurrentQuestionId returns the question most recently asked in channelId. Threads are channels too, so each
+	// thread has its own current question.
+	GetCurrentQuestionId(guildId, channelId string) (string, error)
+	// GetRecentQuestions returns up to limit of the questions asked in channelId, most recently asked first.
+	GetRecentQuestions(guildId, channelId string, limit int) ([]Question, error)
+	GetUnaskedQuestion(guildId, askedBy, channelId string) (Question, error)
+	HasQuestionBeenAsked(guildId, id string) (bool, error)
+
+	// Close stops any background work and flushes whatever hasn't been persisted yet.
+	Close() error
+}
+
+// Option configures optional behaviour of a Storage. Options that don't apply to a backend are ignored by it.
+type Option func(*options)
+
+type options struct {
+	autosaveInterval time.Duration
+	backups          int
+	legacyGuildId    string
+}
+
+// WithAutosaveInterval sets how often LocalStorage writes changed stats to disk in the background. An interval of 0 or
+// less disables autosaving - stats are then only written by Save and Close.
+func WithAutosaveInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.autosaveInterval = interval
+	}
+}
+
+// WithBackups sets how many previous versions of the LocalStorage stats file are kept around (as <path>.1 through
+// <path>.N, newest first). If the stats file can't be read on startup, the newest readable backup is loaded instead.
+func WithBackups(backups int) Option {
+	return func(o *options) {
+		o.backups = max(backups, 0)
+	}
+}
+
+// WithLegacyGuild sets the guild that owns anything saved before stats were kept per guild. Until it's set, that data
+// is kept but isn't visible in any guild.
+func WithLegacyGuild(guildId string) Option {
+	return func(o *options) {
+		o.legacyGuildId = guildId
+	}
 }
 
 type LocalStorage struct {
-	statsLock            sync.RWMutex
-	questionLock         sync.RWMutex
-	currentStats         map[string]PlayerStats
-	statsSavePath        string
-	willOverwriteSave    bool
-	askedQuestions       map[string]bool
-	mostRecentQuestionId string
-	questions            map[string]string
+	lock              sync.RWMutex
+	guilds            map[string]*guildState
+	statsSavePath     string
+	willOverwriteSave bool
+	questions         map[string]string
+	options
+
+	// dirty is set whenever in-memory state changes and cleared once it's been written to disk.
+	dirty        atomic.Bool
+	stopAutosave chan struct{}
+	autosaveDone chan struct{}
+	closeOnce    sync.Once
+}
+
+// guildState is everything LocalStorage knows about a single guild.
+type guildState struct {
+	guildStatsFile
+
+	// asked indexes AskedQuestions by question ID.
+	asked map[string]bool
+	// current is the ID of the question most recently asked in each channel.
+	current map[string]string
+	// answers indexes every player's offer by question ID, then player ID.
+	answers map[string]map[string]uint
+}
+
+func newGuildState(saved guildStatsFile) *guildState {
+	if saved.Players == nil {
+		saved.Players = map[string]PlayerStats{}
+	}
+
+	state := &guildState{
+		guildStatsFile: saved,
+		asked:          make(map[string]bool, len(saved.AskedQuestions)),
+		current:        map[string]string{},
+		answers:        map[string]map[string]uint{},
+	}
+
+	for playerId, stats := range saved.Players {
+		for questionId, offer := range stats.Answered {
+			state.indexAnswer(questionId, playerId, offer)
+		}
+	}
+
+	for _, question := range saved.AskedQuestions {
+		state.asked[question.Id] = true
+		if question.ChannelId != "" {
+			state.current[question.ChannelId] = question.Id
+		}
+	}
+
+	return state
+}
+
+func (g *guildState) indexAnswer(questionId, playerId string, offer uint) {
+	if _, ok := g.answers[questionId]; !ok {
+		g.answers[questionId] = map[string]uint{}
+	}
+
+	g.answers[questionId][playerId] = offer
 }
 
-func NewLocalStorage(statsSavePath string) (*LocalStorage, error) {
+func NewLocalStorage(statsSavePath string, opts ...Option) (*LocalStorage, error) {
 	storage := &LocalStorage{
-		currentStats:      map[string]PlayerStats{},
+		guilds:            map[string]*guildState{},
 		statsSavePath:     statsSavePath,
 		willOverwriteSave: true,
+		options: options{
+			autosaveInterval: defaultAutosaveInterval,
+			backups:          defaultBackups,
+		},
+		stopAutosave: make(chan struct{}),
+		autosaveDone: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&storage.options)
 	}
 
 	if err := storage.loadStats(); err != nil {
 		return nil, fmt.Errorf("can't load stats from disk: %w", err)
 	}
 
-	if err := json.Unmarshal(questionsSerialized, &storage.questions); err != nil {
-		return nil, fmt.Errorf("can't parse questions file: %w", err)
+	var err error
+	if storage.questions, err = loadQuestions(); err != nil {
+		return nil, err
+	}
+
+	if storage.autosaveInterval > 0 {
+		go storage.autosave()
+	} else {
+		close(storage.autosaveDone)
 	}
 
 	return storage, nil
 }
 
+// loadQuestions parses the built in question bank, keyed by question ID.
+func loadQuestions() (map[string]string, error) {
+	var questions map[string]string
+	if err := json.Unmarshal(questionsSerialized, &questions); err != nil {
+		return nil, fmt.Errorf("can't parse questions file: %w", err)
+	}
+
+	return questions, nil
+}
+
+// autosave periodically writes changed stats to disk until Close is called.
+func (s *LocalStorage) autosave() {
+	defer close(s.autosaveDone)
+
+	ticker := time.NewTicker(s.autosaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				log.Printf("autosave failed: %v", err)
+			}
+		case <-s.stopAutosave:
+			return
+		}
+	}
+}
+
+// Save writes the stats to disk if anything has changed since the last save.
+func (s *LocalStorage) Save() error {
+	if !s.dirty.Swap(false) {
+		return nil
+	}
+
+	if err := s.saveStats(); err != nil {
+		// Make sure we try again next time.
+		s.dirty.Store(true)
+		return err
+	}
+
+	return nil
+}
+
+// Close stops autosaving and flushes any unsaved stats to disk. It's safe to call more than once.
+func (s *LocalStorage) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stopAutosave)
+	})
+	<-s.autosaveDone
+
+	return s.Save()
+}
+
 type PlayerStats struct {
 	Answered map[string]uint `json:"answered"`
 }
 
+// clone copies s, so it can be handed out without the storage's lock held.
+func (s PlayerStats) clone() PlayerStats {
+	return PlayerStats{Answered: maps.Clone(s.Answered)}
+}
+
 func (s PlayerStats) GetTotalMoney() uint {
 	var totalMoney uint = 0
 	for _, cost := range s.Answered {
@@ -79,106 +267,148 @@ type Question struct {
 	Text string
 }
 
-// getStats returns the current stats for playerId
-func (s *LocalStorage) GetStats(playerId string) PlayerStats {
-	s.statsLock.RLock()
-	defer s.statsLock.RUnlock()
+// Answer is a single player's answer to a question.
+type Answer struct {
+	PlayerId string
+	Offer    uint
+}
 
-	return s.currentStats[playerId]
+// AskedQuestion records a question being asked. Questions asked before this was tracked only have an Id.
+type AskedQuestion struct {
+	Id        string    `json:"id"`
+	AskedBy   string    `json:"askedBy,omitempty"`
+	AskedAt   time.Time `json:"askedAt,omitzero"`
+	ChannelId string    `json:"channelId,omitempty"`
 }
 
-// saveStats saves the stats currently in memory to disk
-func (s *LocalStorage) saveStats() error {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
+// guild returns the state for guildId, creating it if needed. s.lock must be held for writing.
+func (s *LocalStorage) guild(guildId string) *guildState {
+	guild, ok := s.guilds[guildId]
+	if !ok {
+		guild = newGuildState(guildStatsFile{})
+		s.guilds[guildId] = guild
+	}
 
-	return saveStats(s.currentStats, s.statsSavePath, s.willOverwriteSave)
+	return guild
 }
 
-func saveStats(stats map[string]PlayerStats, filePath string, overwrite bool) error {
-	if _, err := os.Stat(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("error checking file on save: %v", err)
-	} else if err == nil && !overwrite {
-		return errors.New("file exists and overwrite was set to false")
-	}
+// GetStats returns the current stats for playerId. They're a copy, so they're safe to read while others answer.
+func (s *LocalStorage) GetStats(guildId, playerId string) (PlayerStats, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 
-	var file *os.File
-	var err error
-	if file, err = os.Create(filePath); err != nil {
-		return fmt.Errorf("error creating file: %v", err)
+	if guild, ok := s.guilds[guildId]; ok {
+		return guild.Players[playerId].clone(), nil
 	}
 
-	defer file.Close()
+	return PlayerStats{}, nil
+}
+
+// saveStats saves the stats currently in memory to disk
+func (s *LocalStorage) saveStats() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 
-	encoder := json.NewEncoder(file)
-	if err = encoder.Encode(stats); err != nil {
-		return fmt.Errorf("error writing to file: %v", err)
+	file := statsFile{Guilds: make(map[string]guildStatsFile, len(s.guilds))}
+	for guildId, guild := range s.guilds {
+		file.Guilds[guildId] = guild.guildStatsFile
 	}
 
-	return nil
+	return saveStats(file, s.statsSavePath, s.willOverwriteSave, s.backups)
 }
 
 // loadStats loads the stats that are saved on disk, overwriting whatever is in memory
 func (s *LocalStorage) loadStats() error {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	var err error
-	if s.currentStats, s.askedQuestions, err = loadStats(s.statsSavePath); errors.Is(err, os.ErrNotExist) {
-		s.currentStats = map[string]PlayerStats{}
-		s.askedQuestions = map[string]bool{}
+	file, version, err := loadStatsOrBackup(s.statsSavePath, s.backups)
+	if errors.Is(err, os.ErrNotExist) {
+		file = statsFile{}
 	} else if err != nil {
 		return fmt.Errorf("error loading stats: %v", err)
+	} else if version < schemaVersion {
+		// Write the upgraded stats back out - the old file is kept in the backups.
+		log.Printf("upgraded stats from schema version %d to %d", version, schemaVersion)
+		s.dirty.Store(true)
 	}
 
-	return nil
-}
+	s.guilds = make(map[string]*guildState, len(file.Guilds))
+	for guildId, saved := range file.Guilds {
+		s.guilds[guildId] = newGuildState(saved)
+	}
 
-func loadStats(filePath string) (map[string]PlayerStats, map[string]bool, error) {
-	var file *os.File
-	var err error
-	if file, err = os.Open(filePath); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, nil, err
+	if legacy, ok := s.guilds[legacyGuildKey]; ok && s.legacyGuildId != "" {
+		if _, exists := s.guilds[s.legacyGuildId]; exists {
+			log.Printf("guild %s already has stats, leaving stats from before guilds were tracked alone", s.legacyGuildId)
 		} else {
-			return nil, nil, fmt.Errorf("error checking file on load: %v", err)
+			log.Printf("moving stats from before guilds were tracked to guild %s", s.legacyGuildId)
+			s.guilds[s.legacyGuildId] = legacy
+			delete(s.guilds, legacyGuildKey)
+			s.dirty.Store(true)
 		}
 	}
 
-	defer file.Close()
-
-	var stats map[string]PlayerStats
-	decoder := json.NewDecoder(file)
-	if err = decoder.Decode(&stats); err != nil {
-		return nil, nil, fmt.Errorf("error decoding json: %v", err)
-	}
-
-	askedQuestions := make(map[string]bool)
-	for user := range stats {
-		for key := range stats[user].Answered {
-			askedQuestions[key] = true
-		}
-	}
-	return stats, askedQuestions, nil
+	return nil
 }
 
-// RespondToAnswer stores the offer to questionId made by playerId and returns the total amount of money the player now has
-func (s *LocalStorage) UpdateStats(questionId, playerId string, offer uint) PlayerStats {
+// UpdateStats stores the offer to questionId made by playerId and returns the player's updated stats
+func (s *LocalStorage) UpdateStats(guildId, questionId, playerId string, offer uint) (PlayerStats, error) {
 	// TODO: revisit for perf. Probably not a concern unless you want other servers to use this bot.
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	guild := s.guild(guildId)
 
 	var stats PlayerStats
 	var ok bool
-	if stats, ok = s.currentStats[playerId]; !ok {
+	if stats, ok = guild.Players[playerId]; !ok {
 		stats = PlayerStats{
 			Answered: make(map[string]uint),
 		}
 	}
 
 	stats.Answered[questionId] = offer
-	s.currentStats[playerId] = stats
-	return stats
+	guild.Players[playerId] = stats
+	guild.indexAnswer(questionId, playerId, offer)
+	s.dirty.Store(true)
+	return stats.clone(), nil
+}
+
+func (s *LocalStorage) GetLeaderboard(guildId string, metric LeaderboardMetric, offset, limit int) ([]LeaderboardEntry, int, error) {
+	if !slices.Contains(LeaderboardMetrics, metric) {
+		return nil, 0, ErrUnknownMetric
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	guild, ok := s.guilds[guildId]
+	if !ok {
+		return nil, 0, nil
+	}
+
+	entries := rankPlayers(guild.Players, metric)
+	start := min(max(offset, 0), len(entries))
+	end := min(start+max(limit, 0), len(entries))
+	return entries[start:end], len(entries), nil
+}
+
+func (s *LocalStorage) GetAnswers(guildId, questionId string) ([]Answer, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	guild, ok := s.guilds[guildId]
+	if !ok {
+		return nil, nil
+	}
+
+	answers := make([]Answer, 0, len(guild.answers[questionId]))
+	for _, playerId := range slices.Sorted(maps.Keys(guild.answers[questionId])) {
+		answers = append(answers, Answer{PlayerId: playerId, Offer: guild.answers[questionId][playerId]})
+	}
+
+	return answers, nil
 }
 
 func (s *LocalStorage) GetQuestion(id string) (Question, error) {
@@ -189,24 +419,28 @@ func (s *LocalStorage) GetQuestion(id string) (Question, error) {
 	}
 }
 
-func (s *LocalStorage) GetUnaskedQuestion() (Question, error) {
-	s.questionLock.Lock()
-	defer s.questionLock.Unlock()
+// GetUnaskedQuestion picks a random question that hasn't been asked in guildId yet and records that askedBy asked it
+// in channelId.
+func (s *LocalStorage) GetUnaskedQuestion(guildId, askedBy, channelId string) (Question, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	guild := s.guild(guildId)
 
 	// Simple right now - just generate a random number and iterate if we hit a collision.
 	// Later, we should have a pool of IDs that get removed.
 	numQuestions := len(s.questions)
 	bigId, err := rand.Int(rand.Reader, big.NewInt(int64(numQuestions)))
 	if err != nil {
-		return Question{}, nil
+		return Question{}, fmt.Errorf("can't pick a random question: %w", err)
 	}
 
 	// We know this is an int because we have far fewer than 2,147,483,647 hardcoded questions.
 	intId := int(bigId.Int64())
-	if s.HasQuestionBeenAsked(strconv.Itoa(intId)) {
+	if guild.asked[strconv.Itoa(intId)] {
 		foundQuestion := false
-		for i := intId + 1; i != intId; i = (i + 1) % numQuestions {
-			if !s.HasQuestionBeenAsked(strconv.Itoa(i)) {
+		for i := (intId + 1) % numQuestions; i != intId; i = (i + 1) % numQuestions {
+			if !guild.asked[strconv.Itoa(i)] {
 				intId = i
 				foundQuestion = true
 				break
@@ -224,8 +458,15 @@ func (s *LocalStorage) GetUnaskedQuestion() (Question, error) {
 		return Question{}, errors.New("an unknown question ID has been generated")
 	}
 
-	s.askedQuestions[stringId] = true
-	s.mostRecentQuestionId = stringId
+	guild.asked[stringId] = true
+	guild.AskedQuestions = append(guild.AskedQuestions, AskedQuestion{
+		Id:        stringId,
+		AskedBy:   askedBy,
+		AskedAt:   time.Now().UTC(),
+		ChannelId: channelId,
+	})
+	guild.current[channelId] = stringId
+	s.dirty.Store(true)
 
 	log.Print(questionText)
 
@@ -235,14 +476,49 @@ func (s *LocalStorage) GetUnaskedQuestion() (Question, error) {
 	}, nil
 }
 
-func (s *LocalStorage) HasQuestionBeenAsked(id string) bool {
-	return s.askedQuestions[id]
+func (s *LocalStorage) HasQuestionBeenAsked(guildId, id string) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if guild, ok := s.guilds[guildId]; ok {
+		return guild.asked[id], nil
+	}
+
+	return false, nil
 }
 
-func (s *LocalStorage) GetMostRecentQuestionId() (string, error) {
-	if len(s.mostRecentQuestionId) == 0 {
+func (s *LocalStorage) GetCurrentQuestionId(guildId, channelId string) (string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	guild, ok := s.guilds[guildId]
+	if !ok || len(guild.current[channelId]) == 0 {
 		return "", ErrNoQuestionsAsked
 	}
 
-	return s.mostRecentQuestionId, nil
+	return guild.current[channelId], nil
+}
+
+func (s *LocalStorage) GetRecentQuestions(guildId, channelId string, limit int) ([]Question, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	guild, ok := s.guilds[guildId]
+	if !ok {
+		return nil, nil
+	}
+
+	var questions []Question
+	for i := len(guild.AskedQuestions) - 1; i >= 0 && len(questions) < limit; i-- {
+		asked := guild.AskedQuestions[i]
+		if asked.ChannelId != channelId {
+			continue
+		}
+
+		if text, ok := s.questions[asked.Id]; ok {
+			questions = append(questions, Question{Id: asked.Id, Text: text})
+		}
+	}
+
+	return questions, nil
 }
@@ -8,10 +8,13 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -27,14 +30,23 @@ var (
 		},
 	}
 
-	expectedAsked = map[string]bool{
-		"0":  true,
-		"1":  true,
-		"2":  true,
-		"3":  true,
-		"4":  true,
-		"5":  true,
-		"15": true,
+	// Questions that were only ever recorded by being answered, in the order they're migrated.
+	expectedAnswered = []AskedQuestion{
+		{Id: "0"}, {Id: "1"}, {Id: "15"}, {Id: "2"}, {Id: "3"}, {Id: "4"}, {Id: "5"},
+	}
+
+	expectedGuild = guildStatsFile{
+		Players: expectedStats,
+		AskedQuestions: append(slices.Clone(expectedAnswered), AskedQuestion{
+			Id:        "20",
+			AskedBy:   "second",
+			AskedAt:   time.Date(2024, time.May, 1, 20, 30, 0, 0, time.UTC),
+			ChannelId: "general",
+		}),
+	}
+
+	expectedSave = statsFile{
+		Guilds: map[string]guildStatsFile{"guild": expectedGuild},
 	}
 )
 
@@ -50,7 +62,7 @@ func TestMain(m *testing.M) {
 func TestSaveStats(t *testing.T) {
 	t.Run("serializes to file", func(t *testing.T) {
 		savePath := t.TempDir() + testFileName
-		err := saveStats(expectedStats, savePath, true)
+		err := saveStats(expectedSave, savePath, true, 0)
 		assert.NoError(t, err)
 
 		file, err := os.Open(savePath)
@@ -74,43 +86,119 @@ func TestSaveStats(t *testing.T) {
 		err = destination.Close()
 		assert.NoError(t, err)
 
-		testStats := map[string]PlayerStats{
-			"first": {
-				Answered: map[string]uint{"0": 2000000},
+		testStats := statsFile{
+			Guilds: map[string]guildStatsFile{
+				"guild": {
+					Players: map[string]PlayerStats{
+						"first": {
+							Answered: map[string]uint{"0": 2000000},
+						},
+					},
+					AskedQuestions: []AskedQuestion{{Id: "0"}},
+				},
 			},
 		}
 
 		t.Run("errors if no overwrite", func(t *testing.T) {
-			err := saveStats(testStats, savePath, false)
+			err := saveStats(testStats, savePath, false, 0)
 			assert.EqualError(t, err, "file exists and overwrite was set to false")
 		})
 
 		t.Run("overwrites if specified", func(t *testing.T) {
-			err := saveStats(testStats, savePath, true)
+			err := saveStats(testStats, savePath, true, 0)
 			assert.NoError(t, err)
 
-			file, err := os.Open(savePath)
+			savedStats, _, err := loadStats(savePath)
 			assert.NoError(t, err)
-			defer file.Close()
+			assert.Equal(t, testStats, savedStats)
+		})
+	})
+}
 
-			decoder := json.NewDecoder(file)
+func TestSaveStatsBackups(t *testing.T) {
+	savePath := t.TempDir() + testFileName
+	backups := 2
+	for offer := uint(1); offer <= 4; offer++ {
+		stats := statsFile{
+			Guilds: map[string]guildStatsFile{
+				"guild": {
+					Players: map[string]PlayerStats{
+						"first": {Answered: map[string]uint{"0": offer}},
+					},
+				},
+			},
+		}
+		assert.NoError(t, saveStats(stats, savePath, true, backups))
+	}
 
-			var savedStats map[string]PlayerStats
+	t.Run("keeps newest backups", func(t *testing.T) {
+		for n, expectedOffer := range map[int]uint{0: 4, 1: 3, 2: 2} {
+			path := savePath
+			if n > 0 {
+				path = backupPath(savePath, n)
+			}
 
-			err = decoder.Decode(&savedStats)
+			stats, _, err := loadStats(path)
 			assert.NoError(t, err)
-			assert.Equal(t, testStats, savedStats)
-		})
+			assert.Equal(t, expectedOffer, stats.Guilds["guild"].Players["first"].GetTotalMoney())
+		}
+
+		_, err := os.Stat(backupPath(savePath, backups+1))
+		assert.ErrorIs(t, err, os.ErrNotExist)
 	})
+
+	t.Run("falls back to newest valid backup", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(savePath, []byte(`{"first": {"answ`), 0644))
+		assert.NoError(t, os.WriteFile(backupPath(savePath, 1), []byte("not json"), 0644))
+
+		stats, _, err := loadStatsOrBackup(savePath, backups)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), stats.Guilds["guild"].Players["first"].GetTotalMoney())
+	})
+
+	t.Run("surfaces error without backups", func(t *testing.T) {
+		_, _, err := loadStatsOrBackup(savePath, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestRotateBackups(t *testing.T) {
+	for _, backups := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d backups", backups), func(t *testing.T) {
+			savePath := t.TempDir() + testFileName
+			assert.NoError(t, rotateBackups(savePath, backups), "there's nothing to back up yet")
+
+			for _, contents := range []string{"first", "second"} {
+				require.NoError(t, os.WriteFile(savePath, []byte(contents), 0644))
+				require.NoError(t, rotateBackups(savePath, backups))
+
+				// The stats file stays put until it's replaced, so a crash before then doesn't lose it.
+				saved, err := os.ReadFile(savePath)
+				require.NoError(t, err)
+				assert.Equal(t, contents, string(saved))
+
+				backup, err := os.ReadFile(backupPath(savePath, 1))
+				require.NoError(t, err)
+				assert.Equal(t, contents, string(backup))
+			}
+
+			// Replacing the stats file leaves the backup alone.
+			require.NoError(t, os.Remove(savePath))
+			require.NoError(t, os.WriteFile(savePath, []byte("third"), 0644))
+			backup, err := os.ReadFile(backupPath(savePath, 1))
+			require.NoError(t, err)
+			assert.Equal(t, "second", string(backup))
+		})
+	}
 }
 
 func TestLoadStats(t *testing.T) {
 	t.Run("decodes stats successfully", func(t *testing.T) {
-		actualStats, actualAsked, err := loadStats("./test_stats.json")
+		actual, version, err := loadStats("./test_stats.json")
 		assert.NoError(t, err)
 
-		assert.Equal(t, expectedStats, actualStats)
-		assert.Equal(t, expectedAsked, actualAsked)
+		assert.Equal(t, expectedSave, actual)
+		assert.Equal(t, schemaVersion, version)
 	})
 
 	t.Run("surfaces os error", func(t *testing.T) {
@@ -138,7 +226,8 @@ func TestRespondToAnswer(t *testing.T) {
 		assert.NoError(t, err)
 
 		offer := uint(123456)
-		response := storage.UpdateStats(questionId, player, offer)
+		response, err := storage.UpdateStats("guild", questionId, player, offer)
+		assert.NoError(t, err)
 		assert.Equal(t, offer, response.GetTotalMoney())
 	})
 
@@ -147,8 +236,9 @@ func TestRespondToAnswer(t *testing.T) {
 		assert.NoError(t, err)
 
 		offer := uint(123456)
-		storage.UpdateStats(questionId, player, offer)
-		response := storage.UpdateStats(questionId+"2", player, offer)
+		storage.UpdateStats("guild", questionId, player, offer)
+		response, err := storage.UpdateStats("guild", questionId+"2", player, offer)
+		assert.NoError(t, err)
 		assert.Equal(t, offer*2, response.GetTotalMoney())
 	})
 
@@ -157,10 +247,62 @@ func TestRespondToAnswer(t *testing.T) {
 		assert.NoError(t, err)
 
 		offer := uint(123456)
-		storage.UpdateStats(questionId, player, offer)
+		storage.UpdateStats("guild", questionId, player, offer)
 
 		offer = 1
-		response := storage.UpdateStats(questionId, player, offer)
+		response, err := storage.UpdateStats("guild", questionId, player, offer)
+		assert.NoError(t, err)
 		assert.Equal(t, offer, response.GetTotalMoney())
 	})
 }
+
+func TestClose(t *testing.T) {
+	t.Run("flushes unsaved stats", func(t *testing.T) {
+		savePath := t.TempDir() + testFileName
+		storage, err := NewLocalStorage(savePath, WithAutosaveInterval(0))
+		assert.NoError(t, err)
+
+		storage.UpdateStats("guild", "0", "first", uint(1000000))
+		assert.NoError(t, storage.Close())
+
+		saved, _, err := loadStats(savePath)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1000000), saved.Guilds["guild"].Players["first"].GetTotalMoney())
+	})
+
+	t.Run("doesn't write when nothing changed", func(t *testing.T) {
+		savePath := t.TempDir() + testFileName
+		storage, err := NewLocalStorage(savePath, WithAutosaveInterval(0))
+		assert.NoError(t, err)
+
+		assert.NoError(t, storage.Close())
+		_, err = os.Stat(savePath)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestAskedQuestionsSurviveRestart(t *testing.T) {
+	savePath := t.TempDir() + testFileName
+	storage, err := NewLocalStorage(savePath, WithAutosaveInterval(0))
+	assert.NoError(t, err)
+
+	question, err := storage.GetUnaskedQuestion("guild", "asker", "channel")
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+
+	restarted, err := NewLocalStorage(savePath, WithAutosaveInterval(0))
+	assert.NoError(t, err)
+
+	asked, err := restarted.HasQuestionBeenAsked("guild", question.Id)
+	assert.NoError(t, err)
+	assert.True(t, asked)
+	current, err := restarted.GetCurrentQuestionId("guild", "channel")
+	assert.NoError(t, err)
+	assert.Equal(t, question.Id, current)
+
+	askedLog := restarted.guilds["guild"].AskedQuestions
+	assert.Len(t, askedLog, 1)
+	assert.Equal(t, "asker", askedLog[0].AskedBy)
+	assert.Equal(t, "channel", askedLog[0].ChannelId)
+	assert.False(t, askedLog[0].AskedAt.IsZero())
+}